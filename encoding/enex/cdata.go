@@ -1,62 +1,119 @@
 package enex
 
 import (
+	"bufio"
 	"bytes"
-	"errors"
 	"io"
-	"regexp"
-	"strings"
 )
 
-var reCDATA = regexp.MustCompile(`<!\[CDATA\[(.*?)\]\]>`)
+const (
+	cdataStart = "<![CDATA["
+	cdataEnd   = "]]>"
+)
+
+// fieldEnds are closing tags of the export fields keeping CDATA sections
+var fieldEnds = []string{"</content>", "</recognition>"}
 
-// detectNestedCDATA reads the first 8KB to check for nested or malformed CDATA.
-// Returns whether fixing is needed, and a reader that includes all data.
-func detectNestedCDATA(r io.Reader) (bool, io.Reader, error) {
-	const scanSize = 8192
-	var buf bytes.Buffer
-	limited := io.LimitReader(r, scanSize)
-	tee := io.TeeReader(limited, &buf)
+// cdataFilter repairs nested CDATA sections on the fly.
+// Evernote sometimes produces nested CDATA, which is invalid XML:
+// openings of nested sections are removed together with their endings,
+// and the section of a field always ends before the closing tag of the field
+// even if nested sections are unbalanced.
+type cdataFilter struct {
+	r   *bufio.Reader
+	out bytes.Buffer
+
+	// depth of nested CDATA sections, zero outside CDATA
+	depth int
+}
 
-	chunk, err := io.ReadAll(tee)
-	if err != nil && !errors.Is(err, io.EOF) {
-		return false, nil, err
+func newCDATAFilter(r io.Reader) *cdataFilter {
+	return &cdataFilter{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+func (f *cdataFilter) Read(p []byte) (int, error) {
+	for f.out.Len() == 0 {
+		if err := f.fill(); err != nil {
+			if f.out.Len() > 0 {
+				break
+			}
+			return 0, err
+		}
 	}
-	needsFix := hasNestedCDATA(string(chunk))
-	return needsFix, io.MultiReader(&buf, r), nil
+
+	return f.out.Read(p)
 }
 
-// hasNestedCDATA returns true if the input has unbalanced CDATA tags
-// or any CDATA section contains another CDATA opening tag.
-func hasNestedCDATA(input string) bool {
-	openingTags := strings.Count(input, "<![CDATA[")
-	closingTags := strings.Count(input, "]]>")
-	if openingTags > 1 && openingTags != closingTags {
-		return true
+// fill passes the next chunk of the stream to the output
+func (f *cdataFilter) fill() error {
+	if f.depth == 0 {
+		chunk, err := f.r.ReadSlice('<')
+		f.out.Write(chunk)
+		if err == bufio.ErrBufferFull {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if f.consume(cdataStart[1:]) {
+			f.out.WriteString(cdataStart[1:])
+			f.depth = 1
+		}
+		return nil
 	}
-	cdataStart := strings.Index(input, "<![CDATA[")
-	if cdataStart != -1 {
-		remaining := input[cdataStart+9:]
-		cdataEnd := strings.Index(remaining, "]]>")
-		if cdataEnd != -1 && strings.Contains(remaining[:cdataEnd], "<![CDATA[") {
-			return true
+
+	buf, err := f.r.Peek(max(f.r.Buffered(), 1))
+	if len(buf) == 0 {
+		return err
+	}
+	i := bytes.IndexAny(buf, "<]")
+	if i < 0 {
+		f.out.Write(buf)
+		_, _ = f.r.Discard(len(buf))
+		return nil
+	}
+	f.out.Write(buf[:i])
+	_, _ = f.r.Discard(i)
+
+	switch {
+	case f.consume(cdataStart):
+		// Nested sections are merged into the outer one
+		f.depth++
+	case f.consume(cdataEnd):
+		if f.depth == 1 || f.endsField() {
+			f.out.WriteString(cdataEnd)
+			f.depth = 0
+		} else {
+			f.depth--
 		}
+	default:
+		b, _ := f.r.ReadByte()
+		f.out.WriteByte(b)
 	}
-	return false
+
+	return nil
+}
+
+// consume skips the prefix if it is next in the stream
+func (f *cdataFilter) consume(prefix string) bool {
+	b, _ := f.r.Peek(len(prefix))
+	if string(b) != prefix {
+		return false
+	}
+	_, _ = f.r.Discard(len(prefix))
+
+	return true
 }
 
-// removeNestedCDATA removes nested CDATA tags recursively.
-// Evernote sometimes produces nested CDATA, which is invalid XML.
-func removeNestedCDATA(input string) string {
-	output := reCDATA.ReplaceAllStringFunc(input, func(match string) string {
-		submatch := reCDATA.FindStringSubmatch(match)
-		if len(submatch) > 1 {
-			return submatch[1]
+// endsField reports whether the closing tag of a field follows
+func (f *cdataFilter) endsField() bool {
+	b, _ := f.r.Peek(256)
+	b = bytes.TrimLeft(b, " \t\r\n")
+	for _, end := range fieldEnds {
+		if bytes.HasPrefix(b, []byte(end)) {
+			return true
 		}
-		return match
-	})
-	if output != input {
-		return removeNestedCDATA(output)
 	}
-	return output
+
+	return false
 }
@@ -0,0 +1,156 @@
+package enex
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var streamSize = flag.Int64("stream-size", 64<<20, "size of the synthetic export in bytes, e.g. 4294967296 to test multi-GB input")
+
+func Test_cdataFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "valid",
+			input: `<content><![CDATA[<en-note><div>a</div></en-note>]]></content><title>b</title>`,
+			want:  `<content><![CDATA[<en-note><div>a</div></en-note>]]></content><title>b</title>`,
+		},
+		{
+			name:  "nested",
+			input: `<content><![CDATA[<en-note><![CDATA[>]]><div>a]]</div><![CDATA[x<![CDATA[y]]>]]></en-note>]]></content>`,
+			want:  `<content><![CDATA[<en-note>><div>a]]</div>xy</en-note>]]></content>`,
+		},
+		{
+			name:  "unbalanced",
+			input: "<content><![CDATA[<en-note><![CDATA[<div>a</div></en-note>]]>\n  </content><title>b</title>",
+			want:  "<content><![CDATA[<en-note><div>a</div></en-note>]]>\n  </content><title>b</title>",
+		},
+		{
+			name:  "several sections",
+			input: `<content><![CDATA[a<![CDATA[b]]>]]></content><recognition><![CDATA[<![CDATA[c]]>]]></recognition>`,
+			want:  `<content><![CDATA[ab]]></content><recognition><![CDATA[c]]></recognition>`,
+		},
+		{
+			name:  "brackets",
+			input: `<content><![CDATA[a[0]] ] <b> < ]]></content>`,
+			want:  `<content><![CDATA[a[0]] ] <b> < ]]></content>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Read byte by byte to check markers split between chunks
+			got, err := io.ReadAll(newCDATAFilter(iotest.OneByteReader(strings.NewReader(tt.input))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("cdataFilter = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_cdataFilter_Fixture(t *testing.T) {
+	f, err := os.Open("testdata/cdata.issue.enex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	d, err := NewStreamDecoder(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Note
+	if err := d.Next(&got); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<div>email</div>\n>\n", "<div>text</div>]]\n>\n", "<div lang=\"EN-US\">"} {
+		if !bytes.Contains(got.Content, []byte(want)) {
+			t.Errorf("Next() content = %s, want to contain %s", got.Content, want)
+		}
+	}
+}
+
+// syntheticExport generates an export with nested CDATA in every note
+// without keeping it in memory
+type syntheticExport struct {
+	size, read int64
+	notes      int
+	started    bool
+	done       bool
+	buf        bytes.Buffer
+	// maxHeap is the peak memory usage sampled while reading
+	maxHeap uint64
+}
+
+const syntheticNote = `<note><title>Note %d</title><content><![CDATA[<?xml version="1.0" encoding="UTF-8"?><en-note>` +
+	`<div>%s</div><![CDATA[>]]><div>end</div></en-note>]]></content></note>` + "\n"
+
+func (e *syntheticExport) Read(p []byte) (int, error) {
+	if e.buf.Len() == 0 {
+		switch {
+		case !e.started:
+			e.buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?><en-export>` + "\n")
+			e.started = true
+		case e.done:
+			return 0, io.EOF
+		case e.read >= e.size:
+			e.buf.WriteString("</en-export>\n")
+			e.done = true
+		default:
+			_, _ = fmt.Fprintf(&e.buf, syntheticNote, e.notes, strings.Repeat("text ", 10000))
+			e.notes++
+		}
+		if e.notes%100 == 0 {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			e.maxHeap = max(e.maxHeap, m.HeapAlloc)
+		}
+	}
+	n, _ := e.buf.Read(p)
+	e.read += int64(n)
+
+	return n, nil
+}
+
+func TestStreamDecoder_LargeNestedCDATA(t *testing.T) {
+	export := &syntheticExport{size: *streamSize}
+	d, err := NewStreamDecoder(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cnt := 0
+	for {
+		var n Note
+		err := d.Next(&n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() note %d error = %v", cnt, err)
+		}
+		if !bytes.HasSuffix(n.Content, []byte("</div>><div>end</div>")) {
+			t.Fatalf("Next() note %d content ends with %q", cnt, n.Content[max(len(n.Content)-30, 0):])
+		}
+		cnt++
+	}
+
+	if want := export.notes; cnt != want {
+		t.Errorf("Next() decoded %d notes, want %d", cnt, want)
+	}
+	if export.maxHeap > 32<<20 {
+		t.Errorf("Peak memory usage = %d MB, want the export to be streamed", export.maxHeap>>20)
+	}
+}
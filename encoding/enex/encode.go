@@ -0,0 +1,189 @@
+package enex
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	exportHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export4.dtd">` + "\n"
+	noteHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n" +
+		`<!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">` + "\n"
+)
+
+// Encode writes an export to Evernote enex format
+func Encode(w io.Writer, e *Export) error {
+	enc := NewStreamEncoder(w, e.Date)
+	for i := range e.Notes {
+		if err := enc.Encode(&e.Notes[i]); err != nil {
+			return err
+		}
+	}
+
+	return enc.Close()
+}
+
+// StreamEncoder writes notes one by one, the export is complete after Close
+// Resource data is read with Data.Open, so large attachments are never kept in memory
+type StreamEncoder struct {
+	w       *bufio.Writer
+	date    string
+	started bool
+	err     error
+}
+
+// NewStreamEncoder creates an encoder of an export created at a given date in DateFormat
+func NewStreamEncoder(w io.Writer, date string) *StreamEncoder {
+	return &StreamEncoder{w: bufio.NewWriter(w), date: date}
+}
+
+// Encode writes the next note, Content is the inner XML of en-note element
+func (e *StreamEncoder) Encode(n *Note) error {
+	e.start()
+
+	e.write("<note>\n")
+	e.element("title", n.Title)
+	e.write("<content>")
+	e.cdata(noteHeader + "<en-note>" + string(n.Content) + "</en-note>")
+	e.write("</content>\n")
+	e.element("created", n.Created)
+	e.element("updated", n.Updated)
+	for _, tag := range n.Tags {
+		e.element("tag", tag)
+	}
+	e.attributes(n.Attributes)
+	for _, r := range n.Resources {
+		e.resource(r)
+	}
+	e.write("</note>\n")
+
+	return e.err
+}
+
+// Close completes the export
+func (e *StreamEncoder) Close() error {
+	e.start()
+	e.write("</en-export>\n")
+	if e.err != nil {
+		return e.err
+	}
+
+	return e.w.Flush()
+}
+
+func (e *StreamEncoder) start() {
+	if e.started {
+		return
+	}
+	e.started = true
+	e.write(exportHeader)
+	e.write(`<en-export export-date="`)
+	e.escape(e.date)
+	e.write(`" application="evernote2md">` + "\n")
+}
+
+func (e *StreamEncoder) attributes(a NoteAttributes) {
+	fields := [][2]string{
+		{"subject-date", a.SubjectDate},
+		{"latitude", a.Latitude},
+		{"longitude", a.Longitude},
+		{"altitude", a.Altitude},
+		{"author", a.Author},
+		{"source", a.Source},
+		{"source-url", a.SourceUrl},
+		{"source-application", a.SourceApplication},
+		{"reminder-order", a.ReminderOrder},
+		{"reminder-time", a.ReminderTime},
+		{"reminder-done-time", a.ReminderDoneTime},
+		{"place-name", a.PlaceName},
+		{"content-class", a.ContentClass},
+	}
+
+	e.write("<note-attributes>\n")
+	for _, f := range fields {
+		e.element(f[0], f[1])
+	}
+	for _, d := range a.ApplicationData {
+		e.write(`<application-data key="`)
+		e.escape(d.Key)
+		e.write(`">`)
+		e.escape(d.Value)
+		e.write("</application-data>\n")
+	}
+	e.write("</note-attributes>\n")
+}
+
+func (e *StreamEncoder) resource(r Resource) {
+	e.write("<resource>\n")
+	e.write(`<data encoding="base64">`)
+	e.data(r.Data)
+	e.write("</data>\n")
+	e.element("mime", r.Mime)
+	if r.Width > 0 && r.Height > 0 {
+		e.element("width", fmt.Sprint(r.Width))
+		e.element("height", fmt.Sprint(r.Height))
+	}
+	if len(r.Recognition) > 0 {
+		e.write("<recognition>")
+		e.cdata(string(r.Recognition))
+		e.write("</recognition>\n")
+	}
+	e.write("<resource-attributes>\n")
+	e.element("source-url", r.Attributes.SourceUrl)
+	e.element("timestamp", r.Attributes.Timestamp)
+	e.element("file-name", r.Attributes.Filename)
+	e.write("</resource-attributes>\n")
+	e.write("</resource>\n")
+}
+
+func (e *StreamEncoder) data(d Data) {
+	if e.err != nil {
+		return
+	}
+	r, err := d.Open()
+	if err != nil {
+		e.err = fmt.Errorf("encode resource data: %w", err)
+		return
+	}
+	defer func() { _ = r.Close() }()
+
+	b64 := base64.NewEncoder(base64.StdEncoding, e.w)
+	if _, err = io.Copy(b64, r); err == nil {
+		err = b64.Close()
+	}
+	if err != nil {
+		e.err = fmt.Errorf("encode resource data: %w", err)
+	}
+}
+
+// element writes a text element unless the value is empty
+func (e *StreamEncoder) element(name, value string) {
+	if value == "" {
+		return
+	}
+	e.write("<" + name + ">")
+	e.escape(value)
+	e.write("</" + name + ">\n")
+}
+
+// cdata writes a CDATA section, splitting it where the text contains the end marker
+func (e *StreamEncoder) cdata(text string) {
+	e.write(cdataStart + strings.ReplaceAll(text, cdataEnd, "]]"+cdataEnd+cdataStart+">") + cdataEnd)
+}
+
+func (e *StreamEncoder) escape(text string) {
+	if e.err == nil {
+		e.err = xml.EscapeText(e.w, []byte(text))
+	}
+}
+
+func (e *StreamEncoder) write(s string) {
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
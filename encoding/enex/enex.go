@@ -2,12 +2,15 @@ package enex
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type (
@@ -28,17 +31,63 @@ type (
 		Tags       []string       `xml:"tag"`
 		Attributes NoteAttributes `xml:"note-attributes"`
 		Resources  []Resource     `xml:"resource"`
+		Tasks      []Task         `xml:"task"`
+
+		// Notebook is not a part of the export, it is named after the export file
+		Notebook string `xml:"-"`
 	}
 
 	// NoteAttributes contain the note metadata
 	NoteAttributes struct {
-		Source            string `xml:"source"`
-		SourceApplication string `xml:"source-application"`
-		Latitude          string `xml:"latitude"`
-		Longitude         string `xml:"longitude"`
-		Altitude          string `xml:"altitude"`
-		Author            string `xml:"author"`
-		SourceUrl         string `xml:"source-url"`
+		SubjectDate       string            `xml:"subject-date"`
+		Source            string            `xml:"source"`
+		SourceApplication string            `xml:"source-application"`
+		Latitude          string            `xml:"latitude"`
+		Longitude         string            `xml:"longitude"`
+		Altitude          string            `xml:"altitude"`
+		Author            string            `xml:"author"`
+		SourceUrl         string            `xml:"source-url"`
+		ReminderOrder     string            `xml:"reminder-order"`
+		ReminderTime      string            `xml:"reminder-time"`
+		ReminderDoneTime  string            `xml:"reminder-done-time"`
+		PlaceName         string            `xml:"place-name"`
+		ContentClass      string            `xml:"content-class"`
+		LastEditedBy      string            `xml:"last-edited-by"`
+		ApplicationData   []ApplicationData `xml:"application-data"`
+	}
+
+	// ApplicationData is a value stored in the note by a third-party application
+	ApplicationData struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+
+	// Task is a to-do item of Evernote v10 placed in a task group of the note content
+	Task struct {
+		Title   string `xml:"title"`
+		Created string `xml:"created"`
+		Updated string `xml:"updated"`
+		// Status is either "open" or "completed"
+		Status     string `xml:"taskStatus"`
+		InNote     bool   `xml:"inNote"`
+		Flag       bool   `xml:"taskFlag"`
+		Priority   string `xml:"priority"`
+		Assignee   string `xml:"assignee"`
+		SortWeight string `xml:"sortWeight"`
+		ID         string `xml:"noteLevelID"`
+		// GroupID refers to a task group placeholder in the note content
+		GroupID         string         `xml:"taskGroupNoteLevelID"`
+		DueDate         string         `xml:"dueDate"`
+		DueDateUIOption string         `xml:"dueDateUIOption"`
+		TimeZone        string         `xml:"timeZone"`
+		StatusUpdated   string         `xml:"statusUpdated"`
+		Reminders       []TaskReminder `xml:"reminder"`
+	}
+
+	// TaskReminder is a reminder of the task
+	TaskReminder struct {
+		Date   string `xml:"reminderDate"`
+		Status string `xml:"reminderStatus"`
 	}
 
 	// Resource embedded in the note
@@ -67,10 +116,18 @@ type (
 	}
 
 	// Data object in base64
+	// StreamDecoder keeps the decoded data in a temporary file instead of Content, use Open to read it
 	Data struct {
 		XMLName  xml.Name `xml:"data"`
 		Encoding string   `xml:"encoding,attr"`
 		Content  []byte   `xml:",innerxml"`
+
+		// file with the decoded data
+		file string
+		// temp is set when the file was created by StreamDecoder
+		temp bool
+		// err is an error decoding the data to the file
+		err error
 	}
 
 	// Content of Evernote Notes
@@ -79,6 +136,67 @@ type (
 	}
 )
 
+// DateFormat is a format of dates in the export, e.g. 20180109T173725Z
+const DateFormat = "20060102T150405Z"
+
+// ParseDate parses a date from the export
+func ParseDate(date string) (time.Time, error) {
+	return time.Parse(DateFormat, strings.TrimSpace(date))
+}
+
+// Completed reports whether the task is done
+func (t Task) Completed() bool {
+	return t.Status == "completed"
+}
+
+// HasReminder reports whether the note is a reminder, which can be done, scheduled or just pinned to the top
+func (a NoteAttributes) HasReminder() bool {
+	return a.ReminderOrder != "" || a.ReminderTime != "" || a.ReminderDoneTime != ""
+}
+
+// FileData refers to a file with the data of a resource to read it only when needed
+func FileData(path string) Data {
+	return Data{XMLName: xml.Name{Local: "data"}, Encoding: "base64", file: path}
+}
+
+// Open returns a reader of the decoded data
+func (d Data) Open() (io.ReadCloser, error) {
+	switch {
+	case d.err != nil:
+		return nil, d.err
+	case d.file != "":
+		return os.Open(d.file)
+	case d.Encoding == "base64" || isBase64Encoded(d.Content):
+		return io.NopCloser(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(bytes.TrimSpace(d.Content)))), nil
+	}
+
+	return io.NopCloser(bytes.NewReader(d.Content)), nil
+}
+
+var reBase64 = regexp.MustCompile(`^([A-Za-z0-9+/]{4})*([A-Za-z0-9+/]{3}=|[A-Za-z0-9+/]{2}==)?$`)
+
+func isBase64Encoded(content []byte) bool {
+	return reBase64.Match(content)
+}
+
+// Close removes temporary files with the data of resources
+// The note can't be converted after that
+func (n *Note) Close() error {
+	var err error
+	for i := range n.Resources {
+		d := &n.Resources[i].Data
+		if !d.temp {
+			continue
+		}
+		if rmErr := os.Remove(d.file); rmErr != nil && !os.IsNotExist(rmErr) {
+			err = rmErr
+		}
+		d.file, d.temp = "", false
+	}
+
+	return err
+}
+
 var hashRe = regexp.MustCompile(`\b[0-9a-f]{32}\b`)
 
 // Decode will return an Export from evernote
@@ -119,47 +237,45 @@ func (d Decoder) Decode(v any) error {
 	return d.xml.Decode(v)
 }
 
+// StreamDecoder reads notes one by one saving the data of resources to temporary files
+// to keep memory usage low regardless of the size of attachments
+// Close every note to remove its temporary files
 type StreamDecoder struct {
-	xml *xml.Decoder
+	xml   *xml.Decoder
+	spill *spiller
 }
 
 func NewStreamDecoder(r io.Reader) (*StreamDecoder, error) {
-	needsCDATAFix, reader, err := detectNestedCDATA(r)
-	if err != nil {
-		return nil, err
-	}
-
-	var decoder *xml.Decoder
-	if needsCDATAFix {
-		buf := new(bytes.Buffer)
-		if _, err := buf.ReadFrom(reader); err != nil {
-			return nil, err
-		}
-		content := buf.String()
-		content = removeNestedCDATA(content)
-		decoder = xml.NewDecoder(strings.NewReader(content))
-	} else {
-		decoder = xml.NewDecoder(reader)
-	}
+	spill := newSpiller(newCDATAFilter(r))
+	decoder := xml.NewDecoder(spill)
 	decoder.Strict = false
 
 	if err := findEnExportElement(decoder); err != nil {
+		spill.removeAll()
 		return nil, err
 	}
 
-	return &StreamDecoder{xml: decoder}, nil
+	return &StreamDecoder{xml: decoder, spill: spill}, nil
+}
+
+// DiscardResourceData skips the data of resources when only the notes themselves are needed
+func (d StreamDecoder) DiscardResourceData() {
+	d.spill.discard = true
 }
 
 func (d StreamDecoder) Next(n *Note) error {
 	for {
 		token, err := d.xml.Token()
 		if err != nil {
+			// The data of the rest of the stream won't be claimed
+			d.spill.removeAll()
 			return err
 		}
 		element, ok := token.(xml.StartElement)
 
 		if ok && element.Name.Local == "note" {
 			err = d.xml.DecodeElement(n, &element)
+			d.spill.resolve(n)
 			if err != nil {
 				return err
 			}
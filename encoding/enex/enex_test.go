@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/wormi4ok/evernote2md/encoding/enex"
@@ -121,6 +122,15 @@ func TestStreamDecoder(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	// Resource data is streamed to a temporary file
+	want, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(expect.Notes[0].Resources[0].Data.Content)))
+	if data := readData(t, got.Resources[0].Data); !bytes.Equal(data, want) {
+		t.Errorf("Next() resource data = %v,\nwant %v", data, want)
+	}
+	if err := got.Close(); err != nil {
+		t.Error(err)
+	}
+	got.Resources[0].Data = expect.Notes[0].Resources[0].Data
 	if !reflect.DeepEqual(got, expect.Notes[0]) {
 		t.Errorf("Next() = %+v,\nwant %+v", got, expect.Notes[0])
 	}
@@ -205,3 +215,291 @@ func readFile(filename string) []byte {
 	}
 	return append(b.Bytes(), []byte(`Ow==`)...)
 }
+
+func TestDecodeNoteAttributes(t *testing.T) {
+	enexContent, err := os.Open("testdata/attributes.enex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := enex.Decode(enexContent)
+	if err != nil {
+		t.Fatalf("Error while Decoding = %v", err)
+	}
+
+	want := enex.NoteAttributes{
+		SubjectDate:       "20201130T000000Z",
+		Source:            "web.clip",
+		SourceApplication: "evernote.win32",
+		Latitude:          "52.52000000000000",
+		Longitude:         "13.40500000000000",
+		Altitude:          "34.00000000000000",
+		Author:            "Jane Doe",
+		SourceUrl:         "https://example.com/bank",
+		ReminderOrder:     "1606813200000",
+		ReminderTime:      "20201205T080000Z",
+		ReminderDoneTime:  "20201205T093000Z",
+		PlaceName:         "Berlin",
+		ContentClass:      "evernote.checklist",
+		LastEditedBy:      "John Doe",
+		ApplicationData: []enex.ApplicationData{
+			{Key: "com.example.app", Value: `{"id":1}`},
+			{Key: "com.example.other", Value: "value"},
+		},
+	}
+	if !reflect.DeepEqual(got.Notes[0].Attributes, want) {
+		t.Errorf("Decode() attributes = %+v,\nwant %+v", got.Notes[0].Attributes, want)
+	}
+	if !got.Notes[0].Attributes.HasReminder() {
+		t.Error("HasReminder() = false, want true")
+	}
+}
+
+func TestDecodeTasks(t *testing.T) {
+	enexContent, err := os.Open("testdata/tasks.enex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := enex.NewStreamDecoder(enexContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got enex.Note
+	if err = d.Next(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []enex.Task{
+		{
+			Title:           "Book a van",
+			Created:         "20240401T090000Z",
+			Updated:         "20240402T090000Z",
+			Status:          "open",
+			InNote:          true,
+			Flag:            true,
+			SortWeight:      "B",
+			ID:              "a1",
+			GroupID:         "9d2a4e3c-group",
+			DueDate:         "20240501T100000Z",
+			DueDateUIOption: "date_only",
+			TimeZone:        "Europe/Berlin",
+			StatusUpdated:   "20240401T090000Z",
+			Reminders:       []enex.TaskReminder{{Date: "20240430T080000Z", Status: "active"}},
+		},
+		{
+			Title:         "Pack books",
+			Created:       "20240401T090000Z",
+			Updated:       "20240403T090000Z",
+			Status:        "completed",
+			InNote:        true,
+			SortWeight:    "A",
+			ID:            "a2",
+			GroupID:       "9d2a4e3c-group",
+			StatusUpdated: "20240403T090000Z",
+		},
+	}
+	if !reflect.DeepEqual(got.Tasks, want) {
+		t.Errorf("Next() tasks = %+v,\nwant %+v", got.Tasks, want)
+	}
+	if got.Tasks[0].Completed() || !got.Tasks[1].Completed() {
+		t.Error("Completed() doesn't match the task status")
+	}
+}
+
+func TestData_Open(t *testing.T) {
+	want := []byte("sample text")
+	encoded := new(bytes.Buffer)
+	b64encoder := base64.NewEncoder(base64.StdEncoding, encoded)
+
+	if _, err := b64encoder.Write(want); err != nil {
+		t.Error(err)
+	}
+	if err := b64encoder.Close(); err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		name string
+		data enex.Data
+	}{
+		{
+			name: "not encoded",
+			data: enex.Data{
+				Encoding: "",
+				Content:  want,
+			},
+		},
+		{
+			name: "base64 encoded",
+			data: enex.Data{
+				Encoding: "base64",
+				Content:  encoded.Bytes(),
+			},
+		},
+		{
+			name: "base64 encoded - encoding value missing",
+			data: enex.Data{
+				Encoding: "",
+				Content:  encoded.Bytes(),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := tt.data.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Error(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Open() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func readData(t *testing.T, d enex.Data) []byte {
+	t.Helper()
+	r, err := d.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestStreamDecoderSpill(t *testing.T) {
+	// Large enough to cross buffer boundaries
+	large := bytes.Repeat([]byte("evernote2md "), 20000)
+	encoded := base64.StdEncoding.EncodeToString(large)
+	var wrapped strings.Builder
+	for len(encoded) > 76 {
+		wrapped.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	wrapped.WriteString(encoded)
+
+	resource := func(data string) string {
+		return `<resource><data encoding="base64">` + data + `</data><mime>text/plain</mime></resource>`
+	}
+	export := `<?xml version="1.0" encoding="UTF-8"?><en-export>` +
+		`<note><title>Large</title><content><![CDATA[<en-note><div><data encoding="base64">AAAA</data></div></en-note>]]></content>` +
+		resource(wrapped.String()) + resource("c21hbGw=") + `</note>` +
+		`<note><title>Corrupted</title><content><![CDATA[<en-note/>]]></content>` + resource("c21h!!!bGw=") + `</note>` +
+		`</en-export>`
+
+	d, err := enex.NewStreamDecoder(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var note enex.Note
+	if err := d.Next(&note); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = note.Close() }()
+	if !bytes.Contains(note.Content, []byte(`<data encoding="base64">AAAA</data>`)) {
+		t.Errorf("Next() content = %s, want data in the content untouched", note.Content)
+	}
+	if got := readData(t, note.Resources[0].Data); !bytes.Equal(got, large) {
+		t.Errorf("Next() resource data length = %d, want %d", len(got), len(large))
+	}
+	if got := readData(t, note.Resources[1].Data); string(got) != "small" {
+		t.Errorf("Next() resource data = %s, want small", got)
+	}
+	if len(note.Resources[0].Data.Content) != 0 {
+		t.Error("Next() kept the resource data in memory")
+	}
+
+	var corrupted enex.Note
+	if err := d.Next(&corrupted); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = corrupted.Close() }()
+	if _, err := corrupted.Resources[0].Data.Open(); err == nil {
+		t.Error("Open() corrupted data error = nil, want an error")
+	}
+
+	if err := d.Next(&enex.Note{}); err != io.EOF {
+		t.Errorf("Next() = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestStreamDecoderDiscardResourceData(t *testing.T) {
+	export := `<en-export><note><title>Note</title><content><![CDATA[<en-note/>]]></content>` +
+		`<resource><data encoding="base64">c21hbGw=</data></resource></note></en-export>`
+	d, err := enex.NewStreamDecoder(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.DiscardResourceData()
+
+	var got enex.Note
+	if err := d.Next(&got); err != nil {
+		t.Fatal(err)
+	}
+	if data := readData(t, got.Resources[0].Data); len(data) != 0 {
+		t.Errorf("Next() resource data = %s, want it discarded", data)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var b bytes.Buffer
+	if err := enex.Encode(&b, expect); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := enex.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Date != expect.Date || len(got.Notes) != 1 {
+		t.Fatalf("Decode() = %+v, want a round trip of %+v", got, expect)
+	}
+	want, note := expect.Notes[0], got.Notes[0]
+	if note.Title != want.Title || note.Created != want.Created || note.Updated != want.Updated {
+		t.Errorf("Decode() note = %+v, want %+v", note, want)
+	}
+	if !bytes.Equal(note.Content, want.Content) {
+		t.Errorf("Decode() content = %s, want %s", note.Content, want.Content)
+	}
+	if !reflect.DeepEqual(note.Tags, want.Tags) || !reflect.DeepEqual(note.Attributes, want.Attributes) {
+		t.Errorf("Decode() tags = %v attributes = %+v, want %v %+v", note.Tags, note.Attributes, want.Tags, want.Attributes)
+	}
+	if len(note.Resources) != 1 {
+		t.Fatalf("Decode() resources = %d, want 1", len(note.Resources))
+	}
+	r := note.Resources[0]
+	if r.ID != want.Resources[0].ID || r.Mime != want.Resources[0].Mime || r.Attributes != want.Resources[0].Attributes {
+		t.Errorf("Decode() resource = %+v, want %+v", r, want.Resources[0])
+	}
+	if !bytes.Equal(readData(t, r.Data), readData(t, want.Resources[0].Data)) {
+		t.Error("Decode() resource data differs after the round trip")
+	}
+}
+
+func TestStreamEncoderCDATAEnd(t *testing.T) {
+	var b bytes.Buffer
+	enc := enex.NewStreamEncoder(&b, "20200101T000000Z")
+	if err := enc.Encode(&enex.Note{Title: "End", Content: []byte(`<div title="a]]>b">c</div>`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := enex.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<div title="a]]>b">c</div>`; len(got.Notes) != 1 || string(got.Notes[0].Content) != want {
+		t.Errorf("Decode() = %+v, want content %s", got.Notes, want)
+	}
+}
@@ -0,0 +1,225 @@
+package enex
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+var reBase64Encoding = regexp.MustCompile(`\bencoding\s*=\s*["']base64["']`)
+
+type (
+	// spiller moves base64 data of resources from the XML stream to temporary files,
+	// so that large attachments are never kept in memory
+	//
+	// Data is decoded straight to a file and replaced in the stream with a random token,
+	// which the decoder resolves back to the file
+	spiller struct {
+		r   *bufio.Reader
+		out bytes.Buffer
+
+		// cdata is set inside a CDATA section, where data elements are just text
+		cdata bool
+		// tail of the previous chunk to find the end of a CDATA section
+		tail []byte
+
+		// discard skips the data instead of saving it
+		discard bool
+
+		nonce string
+		count int
+		files map[string]spilledData
+	}
+
+	spilledData struct {
+		path string
+		// err is an error decoding the data, it is reported when the data is opened
+		err error
+	}
+
+	// dataBody reads base64 text of a data element until the closing tag
+	// skipping whitespace
+	dataBody struct {
+		r *bufio.Reader
+	}
+)
+
+func newSpiller(r io.Reader) *spiller {
+	nonce := make([]byte, 8)
+	_, _ = rand.Read(nonce)
+
+	return &spiller{
+		r:     bufio.NewReaderSize(r, 64*1024),
+		nonce: hex.EncodeToString(nonce),
+		files: map[string]spilledData{},
+	}
+}
+
+func (s *spiller) Read(p []byte) (int, error) {
+	for s.out.Len() == 0 {
+		if err := s.fill(); err != nil {
+			if s.out.Len() > 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+
+	return s.out.Read(p)
+}
+
+// fill passes the next chunk of the stream to the output
+func (s *spiller) fill() error {
+	if s.cdata {
+		chunk, err := s.r.ReadSlice('>')
+		s.out.Write(chunk)
+		if bytes.HasSuffix(append(s.tail, chunk...), []byte("]]>")) {
+			s.cdata = false
+		}
+		s.tail = append(s.tail[:0], chunk[max(len(chunk)-2, 0):]...)
+		if err == bufio.ErrBufferFull {
+			return nil
+		}
+		return err
+	}
+
+	chunk, err := s.r.ReadSlice('<')
+	s.out.Write(chunk)
+	if err == bufio.ErrBufferFull {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case s.peek("![CDATA["):
+		s.cdata, s.tail = true, s.tail[:0]
+	case s.peek("data") && s.isDataTag():
+		return s.spill()
+	}
+
+	return nil
+}
+
+func (s *spiller) peek(prefix string) bool {
+	b, _ := s.r.Peek(len(prefix))
+
+	return string(b) == prefix
+}
+
+func (s *spiller) isDataTag() bool {
+	b, _ := s.r.Peek(len("data") + 1)
+	if len(b) <= len("data") {
+		return false
+	}
+	switch b[len("data")] {
+	case ' ', '\t', '\r', '\n', '>', '/':
+		return true
+	}
+
+	return false
+}
+
+// spill the body of a data element, which start tag is next in the stream
+func (s *spiller) spill() error {
+	tag, err := s.r.ReadBytes('>')
+	s.out.Write(tag)
+	if err != nil {
+		return err
+	}
+	if bytes.HasSuffix(tag, []byte("/>")) || !reBase64Encoding.Match(tag) {
+		// Empty or not encoded data is left in the stream
+		return nil
+	}
+
+	body := &dataBody{r: s.r}
+	if s.discard {
+		_, err = io.Copy(io.Discard, body)
+		return err
+	}
+
+	f, err := os.CreateTemp("", "evernote2md-*")
+	if err != nil {
+		return fmt.Errorf("spill resource data: %w", err)
+	}
+	_, err = io.Copy(f, base64.NewDecoder(base64.StdEncoding, body))
+	if err != nil {
+		// Skip the rest of the corrupted data
+		_, _ = io.Copy(io.Discard, body)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	s.count++
+	token := fmt.Sprintf("spilled-%s-%d", s.nonce, s.count)
+	s.files[token] = spilledData{path: f.Name(), err: err}
+	s.out.WriteString(token)
+
+	return nil
+}
+
+// resolve replaces tokens in decoded resources with temporary files
+func (s *spiller) resolve(n *Note) {
+	for i := range n.Resources {
+		d := &n.Resources[i].Data
+		token := string(bytes.TrimSpace(d.Content))
+		if spilled, ok := s.files[token]; ok {
+			d.Content, d.file, d.temp, d.err = nil, spilled.path, true, spilled.err
+			delete(s.files, token)
+		}
+	}
+}
+
+// removeAll removes temporary files which were not claimed by notes
+func (s *spiller) removeAll() {
+	for token, spilled := range s.files {
+		_ = os.Remove(spilled.path)
+		delete(s.files, token)
+	}
+}
+
+func (b *dataBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n := 0
+	for n == 0 {
+		chunk, err := b.r.Peek(max(min(len(p), b.r.Buffered()), 1))
+		if len(chunk) == 0 {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		end := bytes.IndexByte(chunk, '<')
+		if end >= 0 {
+			chunk = chunk[:end]
+		}
+		for _, c := range chunk {
+			switch c {
+			case ' ', '\t', '\r', '\n':
+			default:
+				p[n] = c
+				n++
+			}
+		}
+		_, _ = b.r.Discard(len(chunk))
+		if end >= 0 && n == 0 {
+			return 0, io.EOF
+		}
+		if end >= 0 {
+			break
+		}
+	}
+
+	return n, nil
+}
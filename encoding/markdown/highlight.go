@@ -0,0 +1,143 @@
+package markdown
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/godown"
+	"golang.org/x/net/html"
+)
+
+// HighlightStyle defines a markdown representation of highlighted text
+type HighlightStyle string
+
+const (
+	// HighlightSpan is an inline HTML span with a background color
+	HighlightSpan HighlightStyle = "span"
+	// HighlightMark is an inline HTML mark tag with a background color
+	HighlightMark HighlightStyle = "mark"
+	// HighlightEquals is ==text== syntax supported by Obsidian and some other editors
+	HighlightEquals HighlightStyle = "equals"
+)
+
+// ParseHighlightStyle returns a highlight style by name, empty name is returned as is
+func ParseHighlightStyle(name string) (HighlightStyle, error) {
+	switch s := HighlightStyle(strings.ToLower(name)); s {
+	case "", HighlightSpan, HighlightMark, HighlightEquals:
+		return s, nil
+	}
+
+	return "", fmt.Errorf("unknown highlight style %q, supported styles: span, mark, equals", name)
+}
+
+// defaultHighlight is used when a highlight doesn't define its color
+const defaultHighlight = "#ffaaaa"
+
+// HighlightedText is a parsing rule to convert Evernote highlights to text with a background color
+type HighlightedText struct {
+	// Style is HighlightSpan if empty
+	Style HighlightStyle
+	// Colors maps highlight colors, like "yellow" or "#ffef9e", to a CSS class
+	// or to a text label, e.g. an emoji, put in front of the highlighted text
+	// A label is any value which is not a valid class name
+	Colors map[string]string
+}
+
+var reClassName = regexp.MustCompile(`^-?[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Rule implements godown.CustomRule interface to extend basic conversion rules and
+// convert text highlighted in Evernote to an inline HTML tag with its background color
+func (r *HighlightedText) Rule(next godown.WalkFunc) (string, godown.WalkFunc) {
+	return "span", func(node *html.Node, w io.Writer, nest int, option *godown.Option) {
+		open, closing, ok := r.tags(node)
+		if !ok {
+			next(node, w, nest, option)
+			return
+		}
+		_, _ = fmt.Fprint(w, open)
+		next(node, w, nest, option)
+		_, _ = fmt.Fprint(w, closing)
+	}
+}
+
+// tags around the highlighted text, the opening one starts with a label if the color is mapped to it,
+// ok is false if the node is not highlighted
+func (r *HighlightedText) tags(node *html.Node) (open, closing string, ok bool) {
+	color, ok := HighlightColor(node)
+	if !ok {
+		return "", "", false
+	}
+
+	var label string
+	attribute := fmt.Sprintf(` style="background-color: %s"`, html.EscapeString(color))
+	if mapped, found := r.color(node, color); found {
+		if !reClassName.MatchString(mapped) {
+			label = mapped + " "
+		} else {
+			attribute = fmt.Sprintf(` class="%s"`, mapped)
+		}
+	}
+	open, closing = "<span"+attribute+">", "</span>"
+	switch r.Style {
+	case HighlightMark:
+		open, closing = "<mark"+attribute+">", "</mark>"
+	case HighlightEquals:
+		open, closing = "==", "=="
+	}
+
+	return label + open, closing, true
+}
+
+// color looks up the mapping by Evernote color name first and then by the background color
+func (r *HighlightedText) color(node *html.Node, color string) (string, bool) {
+	if name := ParseStyle(strings.ToLower(attr(node, "style")))["--en-highlight"]; name != "" {
+		if mapped, ok := r.Colors[name]; ok {
+			return mapped, true
+		}
+	}
+	mapped, ok := r.Colors[color]
+
+	return mapped, ok
+}
+
+// HighlightColor returns the background color of an Evernote highlight as a lowercase hex value,
+// ok is false if the node is not highlighted
+func HighlightColor(node *html.Node) (color string, ok bool) {
+	style := ParseStyle(strings.ToLower(attr(node, "style")))
+	name := style["--en-highlight"]
+	if name == "" && style["-evernote-highlight"] != "true" {
+		return "", false
+	}
+	if bg := NormalizeColor(style["background-color"]); bg != "" {
+		return bg, true
+	}
+	if name != "" {
+		return name, true
+	}
+
+	return defaultHighlight, true
+}
+
+var reRGB = regexp.MustCompile(`^rgba?\((\d+),(\d+),(\d+)(?:,[\d.]+)?\)$`)
+
+// NormalizeColor converts CSS colors in hex and rgb() notation to a lowercase #rrggbb value,
+// so that the same color always looks the same, other values are returned in lowercase
+func NormalizeColor(color string) string {
+	color = strings.ToLower(strings.ReplaceAll(color, " ", ""))
+	if m := reRGB.FindStringSubmatch(color); m != nil {
+		hex := "#"
+		for _, c := range m[1:] {
+			n, _ := strconv.Atoi(c)
+			hex += fmt.Sprintf("%02x", min(n, 255))
+		}
+		return hex
+	}
+	if len(color) == 4 && color[0] == '#' {
+		return string([]byte{'#', color[1], color[1], color[2], color[2], color[3], color[3]})
+	}
+
+	return color
+}
@@ -0,0 +1,63 @@
+package markdown_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+)
+
+func TestConvert_highlight(t *testing.T) {
+	const legacy = `<span style="background-color: rgb(255, 250, 165);-evernote-highlight:true;">old</span>`
+	const v10 = `<span style="--en-highlight:blue;background-color: #d3e6ff;">new</span>`
+	colors := map[string]string{"blue": "question", "#fffaa5": "🟡"}
+	tests := []struct {
+		name   string
+		style  markdown.HighlightStyle
+		colors map[string]string
+		want   string
+	}{
+		{
+			name: "span",
+			want: `<span style="background-color: #fffaa5">old</span> <span style="background-color: #d3e6ff">new</span>`,
+		},
+		{
+			name:  "mark",
+			style: markdown.HighlightMark,
+			want:  `<mark style="background-color: #fffaa5">old</mark> <mark style="background-color: #d3e6ff">new</mark>`,
+		},
+		{
+			name:   "mapped colors",
+			style:  markdown.HighlightMark,
+			colors: colors,
+			want:   `🟡 <mark style="background-color: #fffaa5">old</mark> <mark class="question">new</mark>`,
+		},
+		{
+			name:   "equals",
+			style:  markdown.HighlightEquals,
+			colors: colors,
+			want:   `🟡 ==old== ==new==`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w bytes.Buffer
+			o := markdown.Options{Highlights: true, HighlightStyle: tt.style, HighlightColors: tt.colors}
+			if err := markdown.Convert(&w, strings.NewReader(legacy+" "+v10), o); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(w.String()); got != tt.want {
+				t.Errorf("Convert() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeColor(t *testing.T) {
+	for color, want := range map[string]string{"rgb(255, 250, 165)": "#fffaa5", "#ABC": "#aabbcc", "Yellow": "yellow"} {
+		if got := markdown.NormalizeColor(color); got != want {
+			t.Errorf("NormalizeColor(%q) = %q, want %q", color, got, want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package markdown
 
 import (
+	"fmt"
 	"io"
 	"time"
 
@@ -15,6 +16,12 @@ const (
 	Image ResourceType = "image"
 	// File should be referenced as an external resource []()
 	File ResourceType = "file"
+	// Audio can be played with an HTML <audio> player
+	Audio ResourceType = "audio"
+	// Video can be played with an HTML <video> player
+	Video ResourceType = "video"
+	// PDF can be embedded in the note with an HTML <object> viewer
+	PDF ResourceType = "pdf"
 )
 
 type (
@@ -25,29 +32,65 @@ type (
 		Media   map[string]Resource
 		CTime   time.Time
 		MTime   time.Time
+		// Warnings describe problems which didn't stop the conversion, like missing attachments
+		Warnings []string
 	}
 
 	// Resource is a media resource related to a markdown note
+	// The content is read on demand to avoid keeping large files in memory
 	Resource struct {
-		Name    string
-		Type    ResourceType
-		Content []byte
+		Name string
+		Type ResourceType
+		// Open returns the content of the resource, it can be called several times
+		Open func() (io.ReadCloser, error)
+		Size int64
 	}
 )
 
+// Warn records a problem found while converting or saving the note
+func (n *Note) Warn(format string, args ...any) {
+	n.Warnings = append(n.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Options control the conversion to markdown
+type Options struct {
+	// Highlights converts Evernote highlights to markdown
+	Highlights bool
+	// HighlightStyle defines how highlighted text looks in markdown
+	HighlightStyle HighlightStyle
+	// HighlightColors maps highlight colors to CSS classes or labels
+	HighlightColors map[string]string
+	// EscapeSpecialChars escapes characters having special meaning in markdown
+	EscapeSpecialChars bool
+	// TodoStyle defines how checkboxes look in markdown
+	TodoStyle TodoStyle
+	// StyledText keeps underline, subscript, superscript and text color as inline HTML
+	StyledText bool
+}
+
 // Convert wraps a call to external dependency to provide
 // stable interface for package users
-func Convert(w io.Writer, r io.Reader, highlights, escapeSpecialChars bool) error {
+func Convert(w io.Writer, r io.Reader, o Options) error {
 	rules := []godown.CustomRule{
-		&TodoItem{}, // Handling checkboxes is always enabled
+		&TodoItem{Style: o.TodoStyle}, // Handling checkboxes is always enabled
+		&WikiLink{},                   // Only used when the input contains wikilinks
+		&Table{Options: o},
+	}
+
+	for _, tag := range []string{"audio", "video", "object"} {
+		rules = append(rules, &EmbeddedMedia{Tag: tag})
+	}
+
+	if o.StyledText {
+		rules = append(rules, &InlineHTML{Tag: "u"}, &InlineHTML{Tag: "sub"}, &InlineHTML{Tag: "sup"}, &TextColor{})
 	}
 
-	if highlights {
-		rules = append(rules, &HighlightedText{})
+	if o.Highlights {
+		rules = append(rules, &HighlightedText{Style: o.HighlightStyle, Colors: o.HighlightColors})
 	}
 
 	return godown.Convert(w, r, &godown.Option{
 		CustomRules: rules,
-		DoNotEscape: !escapeSpecialChars,
+		DoNotEscape: !o.EscapeSpecialChars,
 	})
 }
@@ -1,6 +1,7 @@
 package markdown
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"strings"
@@ -9,45 +10,144 @@ import (
 	"golang.org/x/net/html"
 )
 
-// HighlightedText is a parsing rule to convert Evernote highlights to HTML spans with a background color
-type HighlightedText struct{}
-
-// Rule implements godown.CustomRule interface to extend basic conversion rules and
-// convert text highlighted in Evernote to an inline HTML `span` tag with a custom background color
-func (r *HighlightedText) Rule(next godown.WalkFunc) (string, godown.WalkFunc) {
-	return "span", func(node *html.Node, w io.Writer, nest int, option *godown.Option) {
-		if node.Attr == nil {
-			next(node, w, nest, option)
-			return
-		}
+// WikiLink is a parsing rule to convert links to [[wikilinks]] used by Obsidian and Logseq
+type WikiLink struct{}
 
+// Rule implements godown.CustomRule interface to handle a "wikilink" tag
+// with an "href" attribute, which becomes an embed if "embed" attribute is set
+// Where wikilinks don't work, like in HTML tables, a "link" attribute relative to the note
+// is used instead with an HTML tag for the ResourceType in the "type" attribute
+func (r *WikiLink) Rule(next godown.WalkFunc) (string, godown.WalkFunc) {
+	return "wikilink", func(node *html.Node, w io.Writer, nest int, option *godown.Option) {
+		var target, embed string
 		for _, attr := range node.Attr {
-			if attr.Key == "style" && strings.Contains(attr.Val, "-evernote-highlight:true") {
-				_, _ = fmt.Fprint(w, `<span style="background-color: #ffaaaa">`)
-				next(node, w, nest, option)
-				_, _ = fmt.Fprint(w, "</span>")
-			} else {
-				next(node, w, nest, option)
+			switch attr.Key {
+			case "href":
+				target = attr.Val
+			case "embed":
+				embed = "!"
 			}
 		}
+
+		var text bytes.Buffer
+		next(node, &text, nest, option)
+		alias := strings.TrimSpace(text.String())
+		if alias == "" || alias == target {
+			_, _ = fmt.Fprintf(w, "%s[[%s]]", embed, target)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s[[%s|%s]]", embed, target, alias)
 	}
 }
 
+// TodoStyle defines a markdown representation of checkboxes
+type TodoStyle string
+
+const (
+	// TodoCheckbox is a GitHub Flavoured Markdown task list item
+	TodoCheckbox TodoStyle = "checkbox"
+	// TodoKeyword is a TODO or DONE keyword used by Logseq
+	TodoKeyword TodoStyle = "keyword"
+)
+
 // TodoItem is a parsing rule to convert Evernote checkboxes to corresponding GitHub Flavoured Markdown items
-type TodoItem struct{}
+type TodoItem struct {
+	// Style is TodoCheckbox if empty
+	Style TodoStyle
+}
 
 // Rule implements godown.CustomRule interface to handle Evernote-specific "en-todo" tag
 // It converts the tag to a Markdown format with correct "checked" state
 func (r TodoItem) Rule(next godown.WalkFunc) (string, godown.WalkFunc) {
+	checked, unchecked := "[x] ", "[ ] "
+	if r.Style == TodoKeyword {
+		checked, unchecked = "DONE ", "TODO "
+	}
+
 	return "en-todo", func(node *html.Node, w io.Writer, nest int, option *godown.Option) {
 		for _, attr := range node.Attr {
 			if attr.Key == "checked" && attr.Val == "true" {
-				_, _ = fmt.Fprint(w, "[x] ")
+				_, _ = fmt.Fprint(w, checked)
 				next(node, w, nest, option)
 				return
 			}
 		}
-		_, _ = fmt.Fprint(w, "[ ] ")
+		_, _ = fmt.Fprint(w, unchecked)
 		next(node, w, nest, option)
 	}
 }
+
+// InlineHTML is a parsing rule to keep a formatting tag without a markdown equivalent, like <u>, as inline HTML
+type InlineHTML struct {
+	Tag string
+}
+
+// Rule implements godown.CustomRule interface to wrap converted content of the tag in the same HTML tag
+func (r *InlineHTML) Rule(next godown.WalkFunc) (string, godown.WalkFunc) {
+	return r.Tag, func(node *html.Node, w io.Writer, nest int, option *godown.Option) {
+		_, _ = fmt.Fprintf(w, "<%s>", r.Tag)
+		next(node, w, nest, option)
+		_, _ = fmt.Fprintf(w, "</%s>", r.Tag)
+	}
+}
+
+// TextColor is a parsing rule to convert colored text to an HTML span with a color style
+type TextColor struct{}
+
+// Rule implements godown.CustomRule interface to handle a "font" tag with a "color" attribute
+func (r *TextColor) Rule(next godown.WalkFunc) (string, godown.WalkFunc) {
+	return "font", func(node *html.Node, w io.Writer, nest int, option *godown.Option) {
+		open, closing, ok := r.tags(node)
+		if !ok {
+			next(node, w, nest, option)
+			return
+		}
+		_, _ = fmt.Fprint(w, open)
+		next(node, w, nest, option)
+		_, _ = fmt.Fprint(w, closing)
+	}
+}
+
+// tags around the colored text, ok is false if the color is not set
+func (r *TextColor) tags(node *html.Node) (open, closing string, ok bool) {
+	color := attr(node, "color")
+	if color == "" {
+		return "", "", false
+	}
+
+	return fmt.Sprintf(`<span style="color: %s">`, html.EscapeString(color)), "</span>", true
+}
+
+// EmbeddedMedia is a parsing rule to keep an HTML player or viewer of an attachment, like <audio>,
+// with a link to the file inside for applications which don't support the tag
+// The link is made of "src" or "data" attribute and the "title"
+type EmbeddedMedia struct {
+	Tag string
+}
+
+// Rule implements godown.CustomRule interface to render the tag as inline HTML
+func (r *EmbeddedMedia) Rule(_ godown.WalkFunc) (string, godown.WalkFunc) {
+	return r.Tag, func(node *html.Node, w io.Writer, _ int, _ *godown.Option) {
+		writeEmbeddedMedia(w, node)
+	}
+}
+
+func writeEmbeddedMedia(w io.Writer, node *html.Node) {
+	var b strings.Builder
+	b.WriteString("<" + node.Data)
+	for _, a := range node.Attr {
+		switch {
+		case a.Key == "title":
+		case a.Val == "":
+			b.WriteString(" " + a.Key)
+		default:
+			fmt.Fprintf(&b, ` %s="%s"`, a.Key, html.EscapeString(a.Val))
+		}
+	}
+	link := attr(node, "src")
+	if link == "" {
+		link = attr(node, "data")
+	}
+	fmt.Fprintf(&b, `><a href="%s">%s</a></%s>`, html.EscapeString(link), html.EscapeString(attr(node, "title")), node.Data)
+	_, _ = fmt.Fprint(w, b.String())
+}
@@ -0,0 +1,35 @@
+package markdown
+
+import (
+	"sort"
+	"strings"
+)
+
+// Style is a parsed inline style attribute with lowercase property names,
+// values are trimmed and keep their case
+type Style map[string]string
+
+// ParseStyle parses declarations of an inline style attribute, the last declaration of a property wins
+func ParseStyle(s string) Style {
+	style := Style{}
+	for decl := range strings.SplitSeq(s, ";") {
+		prop, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		style[strings.ToLower(strings.TrimSpace(prop))] = strings.TrimSpace(value)
+	}
+
+	return style
+}
+
+// String renders the style back to an attribute value, properties are sorted to keep the output stable
+func (s Style) String() string {
+	props := make([]string, 0, len(s))
+	for prop, value := range s {
+		props = append(props, prop+":"+value)
+	}
+	sort.Strings(props)
+
+	return strings.Join(props, ";")
+}
@@ -0,0 +1,19 @@
+package markdown_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+)
+
+func TestParseStyle(t *testing.T) {
+	got := markdown.ParseStyle(" Color: #FF0000; --en-id: Group1;invalid; color:blue;;")
+	want := markdown.Style{"color": "blue", "--en-id": "Group1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseStyle() = %v, want %v", got, want)
+	}
+	if s := got.String(); s != "--en-id:Group1;color:blue" {
+		t.Errorf("String() = %s", s)
+	}
+}
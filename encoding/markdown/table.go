@@ -0,0 +1,412 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/mattn/godown"
+	"golang.org/x/net/html"
+)
+
+// Table is a parsing rule to convert HTML tables
+//
+// Tables GitHub Flavoured Markdown can represent become aligned pipe tables,
+// line breaks inside cells are kept as <br>. The first row is the header,
+// unless the table has a header row of its own.
+// Tables with merged cells or block content in cells, like lists or nested tables,
+// are kept as clean HTML tables instead.
+type Table struct {
+	// Options of the conversion, highlights and text colors in HTML tables
+	// follow them the same way as in the rest of the note
+	Options Options
+}
+
+type (
+	tableRow struct {
+		cells  []*html.Node
+		header bool
+	}
+
+	// tableAlign is a column alignment in GFM delimiter row syntax
+	tableAlign int
+)
+
+const (
+	alignNone tableAlign = iota
+	alignLeft
+	alignCenter
+	alignRight
+)
+
+// cellBlocks can't be represented inside a cell of a pipe table
+var cellBlocks = map[string]bool{
+	"table": true, "ul": true, "ol": true, "pre": true, "blockquote": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// Rule implements godown.CustomRule interface to handle a "table" tag
+func (r *Table) Rule(next godown.WalkFunc) (string, godown.WalkFunc) {
+	return "table", func(node *html.Node, w io.Writer, nest int, option *godown.Option) {
+		rows := tableRows(node)
+		if len(rows) == 0 {
+			return
+		}
+		_, _ = fmt.Fprint(w, "\n")
+		if !isPipeTable(rows) {
+			r.writeHTMLTable(w, rows)
+			_, _ = fmt.Fprint(w, "\n")
+			return
+		}
+		writePipeTable(w, rows, func(cell *html.Node) string {
+			var buf bytes.Buffer
+			next(cell, &buf, nest, option)
+			return pipeCell(buf.String())
+		})
+		_, _ = fmt.Fprint(w, "\n")
+	}
+}
+
+// tableRows collects rows of the table from all sections, header rows go first
+func tableRows(table *html.Node) []tableRow {
+	var head, body []tableRow
+	var collect func(n *html.Node, inHead bool)
+	collect = func(n *html.Node, inHead bool) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch strings.ToLower(c.Data) {
+			case "thead":
+				collect(c, true)
+			case "tbody", "tfoot":
+				collect(c, false)
+			case "tr":
+				row := tableRow{header: inHead}
+				allTH := true
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type != html.ElementNode {
+						continue
+					}
+					switch strings.ToLower(cell.Data) {
+					case "th":
+						row.cells = append(row.cells, cell)
+					case "td":
+						row.cells = append(row.cells, cell)
+						allTH = false
+					}
+				}
+				if len(row.cells) == 0 {
+					continue
+				}
+				// A row of header cells on top of the table is a header as well
+				row.header = row.header || (allTH && len(head) == 0 && len(body) == 0)
+				if row.header {
+					head = append(head, row)
+				} else {
+					body = append(body, row)
+				}
+			}
+		}
+	}
+	collect(table, false)
+
+	return append(head, body...)
+}
+
+// isPipeTable reports whether the table fits GFM syntax
+func isPipeTable(rows []tableRow) bool {
+	headers := 0
+	for _, row := range rows {
+		if row.header {
+			headers++
+		}
+		for _, cell := range row.cells {
+			if span(cell, "colspan") > 1 || span(cell, "rowspan") > 1 || hasBlock(cell) {
+				return false
+			}
+		}
+	}
+
+	return headers <= 1
+}
+
+func span(cell *html.Node, key string) int {
+	n := 1
+	_, _ = fmt.Sscanf(attr(cell, key), "%d", &n)
+
+	return n
+}
+
+func hasBlock(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (cellBlocks[strings.ToLower(c.Data)] || hasBlock(c)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+// pipeCell turns converted content of a cell into a single line
+func pipeCell(content string) string {
+	var lines []string
+	for line := range strings.SplitSeq(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, strings.ReplaceAll(line, "|", `\|`))
+		}
+	}
+
+	return strings.Join(lines, "<br>")
+}
+
+func writePipeTable(w io.Writer, rows []tableRow, convert func(cell *html.Node) string) {
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row.cells))
+	}
+
+	text := make([][]string, len(rows))
+	widths := make([]int, columns)
+	aligns := make([]tableAlign, columns)
+	for i, row := range rows {
+		text[i] = make([]string, columns)
+		for j, cell := range row.cells {
+			text[i][j] = convert(cell)
+			widths[j] = max(widths[j], runewidth.StringWidth(text[i][j]), 3)
+			if aligns[j] == alignNone {
+				aligns[j] = cellAlign(cell)
+			}
+		}
+	}
+
+	writeRow := func(cells []string) {
+		for j, cell := range cells {
+			_, _ = fmt.Fprintf(w, "| %s%s ", cell, strings.Repeat(" ", widths[j]-runewidth.StringWidth(cell)))
+		}
+		_, _ = fmt.Fprint(w, "|\n")
+	}
+	writeRow(text[0])
+	delimiters := make([]string, columns)
+	for j, align := range aligns {
+		delimiters[j] = align.delimiter(widths[j])
+	}
+	writeRow(delimiters)
+	for _, cells := range text[1:] {
+		writeRow(cells)
+	}
+}
+
+func cellAlign(cell *html.Node) tableAlign {
+	align := attr(cell, "align")
+	if a := ParseStyle(attr(cell, "style"))["text-align"]; a != "" {
+		align = a
+	}
+	switch strings.ToLower(align) {
+	case "left":
+		return alignLeft
+	case "center":
+		return alignCenter
+	case "right":
+		return alignRight
+	}
+
+	return alignNone
+}
+
+func (a tableAlign) delimiter(width int) string {
+	switch a {
+	case alignLeft:
+		return ":" + strings.Repeat("-", width-1)
+	case alignCenter:
+		return ":" + strings.Repeat("-", width-2) + ":"
+	case alignRight:
+		return strings.Repeat("-", width-1) + ":"
+	}
+
+	return strings.Repeat("-", width)
+}
+
+// tableAttributes are kept in HTML tables, everything else, like styles, is dropped
+var tableAttributes = map[string]bool{"colspan": true, "rowspan": true, "align": true, "href": true, "src": true, "alt": true, "title": true}
+
+// tableElements are kept in HTML tables, other elements are replaced with their content
+var tableElements = map[string]bool{
+	"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "td": true, "th": true,
+	"a": true, "b": true, "strong": true, "i": true, "em": true, "u": true, "s": true, "del": true,
+	"sub": true, "sup": true, "code": true, "pre": true, "br": true, "p": true, "div": true, "img": true,
+	"ul": true, "ol": true, "li": true, "blockquote": true, "hr": true, "mark": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+var reSpaces = regexp.MustCompile(`[[:space:]]+`)
+
+// writeHTMLTable renders the table without styles and Evernote specific markup
+// Rows are on separate lines without blank lines, so that the table stays one HTML block
+func (r *Table) writeHTMLTable(w io.Writer, rows []tableRow) {
+	_, _ = fmt.Fprint(w, "<table>\n")
+	inHead := false
+	for i, row := range rows {
+		if row.header && !inHead {
+			_, _ = fmt.Fprint(w, "<thead>\n")
+			inHead = true
+		}
+		if !row.header && (inHead || i == 0) {
+			if inHead {
+				_, _ = fmt.Fprint(w, "</thead>\n")
+				inHead = false
+			}
+			_, _ = fmt.Fprint(w, "<tbody>\n")
+		}
+		_, _ = fmt.Fprint(w, "<tr>")
+		for _, cell := range row.cells {
+			r.writeHTMLNode(w, cell)
+		}
+		_, _ = fmt.Fprint(w, "</tr>\n")
+	}
+	if inHead {
+		_, _ = fmt.Fprint(w, "</thead>\n")
+	} else {
+		_, _ = fmt.Fprint(w, "</tbody>\n")
+	}
+	_, _ = fmt.Fprint(w, "</table>\n")
+}
+
+func (r *Table) writeHTMLNode(w io.Writer, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		_, _ = fmt.Fprint(w, html.EscapeString(reSpaces.ReplaceAllString(n.Data, " ")))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	tag := strings.ToLower(n.Data)
+	switch {
+	case tag == "en-todo":
+		checked := ""
+		if attr(n, "checked") == "true" {
+			checked = " checked"
+		}
+		_, _ = fmt.Fprintf(w, `<input type="checkbox" disabled%s>`, checked)
+		// Self-closing en-todo tag is parsed as a container of the following text
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			r.writeHTMLNode(w, c)
+		}
+		return
+	case tag == "wikilink":
+		writeWikiLink(w, n)
+		return
+	case tag == "span" && r.Options.Highlights:
+		highlight := &HighlightedText{Style: r.Options.HighlightStyle, Colors: r.Options.HighlightColors}
+		if highlight.Style == HighlightEquals {
+			// Markdown syntax doesn't work inside HTML
+			highlight.Style = HighlightMark
+		}
+		r.writeWrapped(w, n, highlight.tags)
+		return
+	case tag == "audio" || tag == "video" || tag == "object":
+		writeEmbeddedMedia(w, n)
+		return
+	case tag == "font" && r.Options.StyledText:
+		r.writeWrapped(w, n, (&TextColor{}).tags)
+		return
+	case !tableElements[tag]:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			r.writeHTMLNode(w, c)
+		}
+		return
+	}
+
+	if tag == "table" {
+		// Nested tables are rendered the same way
+		_, _ = fmt.Fprint(w, "\n")
+		r.writeHTMLTable(w, tableRows(n))
+		return
+	}
+
+	_, _ = fmt.Fprint(w, "<"+tag)
+	for _, a := range n.Attr {
+		if tableAttributes[a.Key] {
+			_, _ = fmt.Fprintf(w, ` %s="%s"`, a.Key, html.EscapeString(a.Val))
+		}
+	}
+	_, _ = fmt.Fprint(w, ">")
+	switch tag {
+	case "br", "img", "hr":
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.writeHTMLNode(w, c)
+	}
+	_, _ = fmt.Fprintf(w, "</%s>", tag)
+}
+
+// writeWrapped writes the content of the node in the tags of the rule, or just the content
+// if the rule doesn't apply to the node
+func (r *Table) writeWrapped(w io.Writer, n *html.Node, tags func(*html.Node) (string, string, bool)) {
+	open, closing, ok := tags(n)
+	_, _ = fmt.Fprint(w, open)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.writeHTMLNode(w, c)
+	}
+	if ok {
+		_, _ = fmt.Fprint(w, closing)
+	}
+}
+
+// writeWikiLink as an HTML link or an embedded attachment, as wikilinks don't work inside HTML
+func writeWikiLink(w io.Writer, n *html.Node) {
+	target := attr(n, "link")
+	if target == "" {
+		target = attr(n, "href")
+	}
+	var text strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			text.WriteString(c.Data)
+		}
+	}
+	if attr(n, "embed") != "" {
+		writeEmbed(w, ResourceType(attr(n, "type")), target)
+		return
+	}
+	if text.Len() == 0 {
+		text.WriteString(attr(n, "href"))
+	}
+	_, _ = fmt.Fprintf(w, `<a href="%s">%s</a>`, html.EscapeString(target), html.EscapeString(text.String()))
+}
+
+// writeEmbed renders an attachment with the same HTML tag as outside of wikilinks
+func writeEmbed(w io.Writer, t ResourceType, src string) {
+	name := path.Base(src)
+	media := func(tag string, attrs ...html.Attribute) {
+		writeEmbeddedMedia(w, &html.Node{Type: html.ElementNode, Data: tag, Attr: append(attrs, html.Attribute{Key: "title", Val: name})})
+	}
+	switch t {
+	case Audio:
+		media("audio", html.Attribute{Key: "controls"}, html.Attribute{Key: "src", Val: src})
+	case Video:
+		media("video", html.Attribute{Key: "controls"}, html.Attribute{Key: "src", Val: src})
+	case PDF:
+		media("object", html.Attribute{Key: "data", Val: src}, html.Attribute{Key: "type", Val: "application/pdf"},
+			html.Attribute{Key: "width", Val: "100%"}, html.Attribute{Key: "height", Val: "600"})
+	default:
+		_, _ = fmt.Fprintf(w, `<img src="%s" alt="%s">`, html.EscapeString(src), html.EscapeString(name))
+	}
+}
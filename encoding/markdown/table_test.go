@@ -0,0 +1,83 @@
+package markdown_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+)
+
+func TestConvert_table(t *testing.T) {
+	const styledCell = `<table><tr><td colspan="2"><span style="--en-highlight:yellow;background-color: #ffef9e;">marked</span> ` +
+		`<font color="#ff0000">red</font></td></tr></table>`
+	tests := []struct {
+		name string
+		html string
+		opts markdown.Options
+		want string
+	}{
+		{
+			name: "First row is the header",
+			html: `<table><tr><td>a</td><td>b</td></tr><tr><td>long text</td><td>x|y</td></tr></table>`,
+			want: "| a         | b    |\n" +
+				"| --------- | ---- |\n" +
+				"| long text | x\\|y |\n",
+		},
+		{
+			name: "Header row and alignment",
+			html: `<table><tr><th align="right">h1</th><th style="text-align: center">h2</th></tr>` +
+				`<tr><td>first</td><td>second</td></tr></table>`,
+			want: "| h1    | h2     |\n" +
+				"| ----: | :----: |\n" +
+				"| first | second |\n",
+		},
+		{
+			name: "Line breaks in cells",
+			html: `<table><tr><th>Name</th></tr><tr><td><div>one</div><div>two<br/>three</div></td></tr></table>`,
+			want: "| Name                |\n" +
+				"| ------------------- |\n" +
+				"| one<br>two<br>three |\n",
+		},
+		{
+			name: "Merged cells",
+			html: `<table><tr><td colspan="2" style="color: red">wide</td></tr>` +
+				`<tr><td><en-todo checked="true"/>done</td><td><a href="https://example.com" style="x">link</a></td></tr></table>`,
+			want: "<table>\n<tbody>\n" +
+				"<tr><td colspan=\"2\">wide</td></tr>\n" +
+				"<tr><td><input type=\"checkbox\" disabled checked>done</td><td><a href=\"https://example.com\">link</a></td></tr>\n" +
+				"</tbody>\n</table>\n",
+		},
+		{
+			name: "Block content in cells",
+			html: `<table><thead><tr><th>List</th></tr></thead><tbody><tr><td><ul><li>a &amp; b</li></ul></td></tr></tbody></table>`,
+			want: "<table>\n<thead>\n<tr><th>List</th></tr>\n</thead>\n<tbody>\n" +
+				"<tr><td><ul><li>a &amp; b</li></ul></td></tr>\n" +
+				"</tbody>\n</table>\n",
+		},
+		{
+			name: "Highlights and colors are disabled",
+			html: styledCell,
+			want: "<table>\n<tbody>\n<tr><td colspan=\"2\">marked red</td></tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name: "Highlights and colors follow the options",
+			html: styledCell,
+			opts: markdown.Options{Highlights: true, HighlightStyle: markdown.HighlightEquals, HighlightColors: map[string]string{"yellow": "note"}, StyledText: true},
+			want: "<table>\n<tbody>\n" +
+				"<tr><td colspan=\"2\"><mark class=\"note\">marked</mark> <span style=\"color: #ff0000\">red</span></td></tr>\n" +
+				"</tbody>\n</table>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w bytes.Buffer
+			if err := markdown.Convert(&w, strings.NewReader(tt.html), tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(w.String()); got != strings.TrimSpace(tt.want) {
+				t.Errorf("Convert()\n got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
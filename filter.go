@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+)
+
+type (
+	// filterOptions are the values of the flags selecting notes to convert
+	filterOptions struct {
+		tags, excludeTags           []string
+		createdAfter, createdBefore string
+		updatedAfter, updatedBefore string
+		title, source               string
+		hasAttachments              bool
+	}
+
+	// noteFilter selects notes to convert, a nil filter selects all notes
+	noteFilter struct {
+		// notes with any of the tags
+		tags map[string]bool
+		// notes without any of the tags
+		excludeTags map[string]bool
+
+		// date ranges include the start and exclude the end
+		createdAfter, createdBefore time.Time
+		updatedAfter, updatedBefore time.Time
+
+		title *regexp.Regexp
+		// glob pattern of the export file name or path
+		source         string
+		hasAttachments bool
+	}
+)
+
+// newNoteFilter validates the options, it returns nil when no filters are set
+func newNoteFilter(o filterOptions) (*noteFilter, error) {
+	if o.isEmpty() {
+		return nil, nil
+	}
+
+	f := &noteFilter{
+		tags:           tagSet(o.tags),
+		excludeTags:    tagSet(o.excludeTags),
+		source:         filepath.ToSlash(o.source),
+		hasAttachments: o.hasAttachments,
+	}
+	dates := []struct {
+		flag  string
+		value string
+		date  *time.Time
+	}{
+		{"createdAfter", o.createdAfter, &f.createdAfter},
+		{"createdBefore", o.createdBefore, &f.createdBefore},
+		{"updatedAfter", o.updatedAfter, &f.updatedAfter},
+		{"updatedBefore", o.updatedBefore, &f.updatedBefore},
+	}
+	for _, d := range dates {
+		if d.value == "" {
+			continue
+		}
+		t, err := parseFilterDate(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %w", d.flag, err)
+		}
+		*d.date = t
+	}
+
+	if o.title != "" {
+		re, err := regexp.Compile(o.title)
+		if err != nil {
+			return nil, fmt.Errorf("--title: %w", err)
+		}
+		f.title = re
+	}
+	if f.source != "" && !doublestar.ValidatePattern(f.source) {
+		return nil, fmt.Errorf("--source: invalid pattern %s", o.source)
+	}
+
+	return f, nil
+}
+
+func (o filterOptions) isEmpty() bool {
+	return len(o.tags) == 0 && len(o.excludeTags) == 0 &&
+		o.createdAfter == "" && o.createdBefore == "" && o.updatedAfter == "" && o.updatedBefore == "" &&
+		o.title == "" && o.source == "" && !o.hasAttachments
+}
+
+// match reports whether the note from the export file passes all the filters
+func (f *noteFilter) match(exportFile string, note *enex.Note) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.tags) > 0 && !hasAnyTag(note, f.tags) {
+		return false
+	}
+	if hasAnyTag(note, f.excludeTags) {
+		return false
+	}
+	if !inRange(note.Created, f.createdAfter, f.createdBefore) || !inRange(note.Updated, f.updatedAfter, f.updatedBefore) {
+		return false
+	}
+	if f.title != nil && !f.title.MatchString(note.Title) {
+		return false
+	}
+	if f.source != "" && !matchSource(f.source, exportFile) {
+		return false
+	}
+	if f.hasAttachments && len(note.Resources) == 0 {
+		return false
+	}
+
+	return true
+}
+
+// tagSet of lowercase tags, as tags in Evernote are case-insensitive
+func tagSet(tags []string) map[string]bool {
+	set := map[string]bool{}
+	for _, tag := range tags {
+		for t := range strings.SplitSeq(tag, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				set[strings.ToLower(t)] = true
+			}
+		}
+	}
+
+	return set
+}
+
+func hasAnyTag(note *enex.Note, tags map[string]bool) bool {
+	for _, tag := range note.Tags {
+		if tags[strings.ToLower(tag)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inRange reports whether the date of the note is within [after, before),
+// notes without a date are out of any range
+func inRange(date string, after, before time.Time) bool {
+	if after.IsZero() && before.IsZero() {
+		return true
+	}
+	t, err := enex.ParseDate(date)
+	if err != nil {
+		return false
+	}
+
+	return (after.IsZero() || !t.Before(after)) && (before.IsZero() || t.Before(before))
+}
+
+// matchSource matches the pattern against the name of the export file,
+// or against the whole path if the pattern contains directories
+func matchSource(pattern, exportFile string) bool {
+	name := filepath.Base(exportFile)
+	if strings.Contains(pattern, "/") {
+		name = filepath.ToSlash(exportFile)
+		if !strings.HasPrefix(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+	}
+	ok, _ := doublestar.Match(pattern, name)
+
+	return ok
+}
+
+var filterDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parseFilterDate in local time unless the time zone is given
+func parseFilterDate(value string) (time.Time, error) {
+	for _, layout := range filterDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+)
+
+func Test_noteFilter_match(t *testing.T) {
+	note := &enex.Note{
+		Title:     "Project plan 2021",
+		Created:   "20210115T100000Z",
+		Updated:   "\n  20210301T100000Z\n",
+		Tags:      []string{"Work", "planning"},
+		Resources: []enex.Resource{{ID: "1"}},
+	}
+	tests := []struct {
+		name string
+		opts filterOptions
+		file string
+		want bool
+	}{
+		{"tag", filterOptions{tags: []string{"home", "work"}}, "Notes.enex", true},
+		{"comma-separated tags", filterOptions{tags: []string{"home,planning"}}, "Notes.enex", true},
+		{"missing tag", filterOptions{tags: []string{"home"}}, "Notes.enex", false},
+		{"excluded tag", filterOptions{excludeTags: []string{"WORK"}}, "Notes.enex", false},
+		{"created after", filterOptions{createdAfter: "2021-01-01"}, "Notes.enex", true},
+		{"created before", filterOptions{createdBefore: "2021-01-01"}, "Notes.enex", false},
+		{"updated range", filterOptions{updatedAfter: "2021-02-01", updatedBefore: "2021-04-01"}, "Notes.enex", true},
+		{"updated before is exclusive", filterOptions{updatedBefore: "2021-03-01T10:00:00Z"}, "Notes.enex", false},
+		{"title", filterOptions{title: `(?i)^project`}, "Notes.enex", true},
+		{"title mismatch", filterOptions{title: `^Plan`}, "Notes.enex", false},
+		{"source name", filterOptions{source: "Work*.enex"}, filepath.FromSlash("/exports/Work Notes.enex"), true},
+		{"source name mismatch", filterOptions{source: "Work*.enex"}, filepath.FromSlash("/exports/Home.enex"), false},
+		{"source path", filterOptions{source: "exports/*.enex"}, filepath.FromSlash("/home/exports/Home.enex"), true},
+		{"has attachments", filterOptions{hasAttachments: true}, "Notes.enex", true},
+		{"all filters", filterOptions{tags: []string{"work"}, createdAfter: "2021-01-15", title: "plan", hasAttachments: true}, "Notes.enex", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newNoteFilter(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.match(tt.file, note); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_noteFilter_noDates(t *testing.T) {
+	f, _ := newNoteFilter(filterOptions{createdBefore: "2021-01-01"})
+	if f.match("Notes.enex", &enex.Note{Title: "No dates"}) {
+		t.Error("match() = true, want notes without dates out of date ranges")
+	}
+	if f, _ := newNoteFilter(filterOptions{}); f != nil || !f.match("Notes.enex", &enex.Note{}) {
+		t.Error("newNoteFilter() without options should match all notes")
+	}
+}
+
+func Test_newNoteFilter_invalid(t *testing.T) {
+	for name, opts := range map[string]filterOptions{
+		"date":   {createdAfter: "31/01/2021"},
+		"title":  {title: "(unclosed"},
+		"source": {source: "[a-"},
+	} {
+		if _, err := newNoteFilter(opts); err == nil {
+			t.Errorf("newNoteFilter() with invalid %s, want an error", name)
+		}
+	}
+}
+
+const filterFile = `<?xml version="1.0" encoding="UTF-8"?>
+<en-export>
+<note><title>Work</title><content><![CDATA[<en-note><div>work</div></en-note>]]></content><tag>work</tag></note>
+<note><title>Home</title><content><![CDATA[<en-note><div>home</div></en-note>]]></content><tag>home</tag></note>
+</en-export>
+`
+
+func Test_run_filter(t *testing.T) {
+	r := newTestRun(t, tDir(t))
+	r.opts.filter, _ = newNoteFilter(filterOptions{tags: []string{"work"}})
+	r.run(r.export("export.enex", filterFile))
+
+	if _, err := os.Stat(filepath.Join(r.output.Path(), "Work.md")); err != nil {
+		t.Errorf("Work.md was not created: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(r.output.Path(), "Home.md")); !os.IsNotExist(err) {
+		t.Error("Home.md was created, want it skipped by the filter")
+	}
+}
@@ -8,8 +8,10 @@ require (
 	github.com/hako/durafmt v0.0.0-20210608085754-5c1018a4e16b
 	github.com/hashicorp/logutils v1.0.0
 	github.com/integrii/flaggy v1.8.0
+	github.com/mattn/go-runewidth v0.0.19
 	github.com/mattn/godown v0.0.2-0.20210508133137-72c48840c3e3
 	github.com/sergi/go-diff v1.4.0
+	github.com/yuin/goldmark v1.8.2
 	golang.org/x/net v0.48.0
 )
 
@@ -19,7 +21,6 @@ require (
 	github.com/fatih/color v1.18.0 // indirect
 	github.com/mattn/go-colorable v0.1.14 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/mattn/go-runewidth v0.0.19 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/term v0.38.0 // indirect
 )
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+)
+
+// Code replaces div tag stylized to look like code blocks with an actual <pre> tag
+//
+// The language of the code block is taken from Evernote's --en-syntaxLanguage style
+// or guessed from the content, it becomes a "language-" class of the inner <code> tag.
+type Code struct {
+	// DefaultLanguage is used when the language is neither set in Evernote nor recognized
+	DefaultLanguage string
+	// Verbatim keeps the code as is, otherwise trailing whitespace
+	// and blank lines around the code are removed
+	Verbatim bool
+}
+
+// ReplaceTag implements the TagReplacer interface
+func (r *Code) ReplaceTag(n *html.Node) {
+	if !isCode(n) {
+		return
+	}
+
+	var b strings.Builder
+	codeText(n, &b)
+	// Evernote keeps indentation with non-breaking spaces
+	text := strings.ReplaceAll(b.String(), "\u00a0", " ")
+	if !r.Verbatim {
+		text = tidyCode(text)
+	}
+
+	lang, ok := syntaxLanguage(attr(n.Attr, "style"))
+	if !ok {
+		lang = guessLanguage(text)
+	}
+	if !ok && lang == "" {
+		lang = r.DefaultLanguage
+	}
+
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		n.RemoveChild(c)
+	}
+	code := &html.Node{Type: html.ElementNode, Data: "code", DataAtom: atom.Code}
+	if lang != "" {
+		code.Attr = []html.Attribute{{Key: "class", Val: "language-" + lang}}
+	}
+	code.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	n.AppendChild(code)
+	n.Data, n.DataAtom, n.Attr = "pre", atom.Pre, nil
+}
+
+func isCode(n *html.Node) bool {
+	if n.Type == html.ElementNode && n.Data == "div" {
+		for _, a := range n.Attr {
+			if a.Key == "style" {
+				return strings.Contains(a.Val, "-en-codeblock:true")
+			}
+		}
+	}
+
+	return false
+}
+
+// codeText collects text of the code block, every inner div is a line
+func codeText(n *html.Node, b *strings.Builder) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == html.TextNode:
+			b.WriteString(c.Data)
+		case c.Type != html.ElementNode:
+		case c.Data == "br":
+			b.WriteString("\n")
+		case c.Data == "div" || c.Data == "p":
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString("\n")
+			}
+			codeText(c, b)
+			if !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString("\n")
+			}
+		default:
+			codeText(c, b)
+		}
+	}
+}
+
+func tidyCode(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+	text = strings.Trim(strings.Join(lines, "\n"), "\n")
+	if text == "" {
+		return ""
+	}
+
+	return text + "\n"
+}
+
+// syntaxLanguage set in Evernote, plain text means no language, ok is false if not set
+func syntaxLanguage(style string) (lang string, ok bool) {
+	switch lang := strings.ToLower(strings.ReplaceAll(markdown.ParseStyle(style)["--en-syntaxlanguage"], " ", "")); lang {
+	case "":
+		return "", false
+	case "plain", "plaintext", "text", "none":
+		return "", true
+	default:
+		return lang, true
+	}
+}
+
+type languageHint struct {
+	re     *regexp.Regexp
+	weight int
+}
+
+// languageHints are patterns typical for a language, the first language
+// with the highest score of at least minLanguageScore wins
+var languageHints = []struct {
+	lang  string
+	hints []languageHint
+}{
+	{"php", []languageHint{{regexp.MustCompile(`<\?php`), 3}}},
+	{"bash", []languageHint{
+		{regexp.MustCompile(`^#!/(usr/)?bin/(env )?(ba|z)?sh`), 3},
+		{regexp.MustCompile(`(?m)^\s*(\$ )?(sudo|apt|apt-get|brew|echo|export|cd|mkdir|curl|git|chmod) `), 1},
+		{regexp.MustCompile(`(?m)^\s*(if|while) \[`), 1},
+		{regexp.MustCompile(`(?m)^\s*(fi|done|esac)\s*$`), 1},
+	}},
+	{"python", []languageHint{
+		{regexp.MustCompile(`^#!/usr/bin/(env )?python`), 3},
+		{regexp.MustCompile(`(?m)^\s*def \w+\(.*\)( -> .+)?:\s*$`), 2},
+		{regexp.MustCompile(`(?m)^(from [\w.]+ )?import [\w.]+( as \w+)?\s*$`), 1},
+		{regexp.MustCompile(`(?m)^\s*class \w+(\(.*\))?:\s*$`), 2},
+		{regexp.MustCompile(`\bself\.`), 1},
+		{regexp.MustCompile(`(?m)^\s*elif `), 1},
+		{regexp.MustCompile(`\bprint\(`), 1},
+	}},
+	{"go", []languageHint{
+		{regexp.MustCompile(`(?m)^package \w+\s*$`), 2},
+		{regexp.MustCompile(`(?m)^func (\(\w+ \*?\w+\) )?\w+\(`), 2},
+		{regexp.MustCompile(`:= `), 1},
+		{regexp.MustCompile(`\bfmt\.\w+\(`), 1},
+		{regexp.MustCompile(`\bif err != nil`), 2},
+	}},
+	{"rust", []languageHint{
+		{regexp.MustCompile(`\bfn \w+\(`), 1},
+		{regexp.MustCompile(`\blet mut\b`), 2},
+		{regexp.MustCompile(`\bprintln!\(`), 2},
+		{regexp.MustCompile(`(?m)^\s*(impl|use \w+::)`), 1},
+	}},
+	{"cpp", []languageHint{
+		{regexp.MustCompile(`#include <(iostream|vector|string|map)>`), 3},
+		{regexp.MustCompile(`\bstd::`), 2},
+		{regexp.MustCompile(`\bcout <<`), 1},
+	}},
+	{"c", []languageHint{
+		{regexp.MustCompile(`(?m)^#include\s*[<"]`), 2},
+		{regexp.MustCompile(`\bprintf\(`), 1},
+		{regexp.MustCompile(`\bint main\(`), 1},
+	}},
+	{"java", []languageHint{
+		{regexp.MustCompile(`\bpublic (static )?(final )?(class|void|interface) `), 2},
+		{regexp.MustCompile(`\bSystem\.out\.print`), 2},
+		{regexp.MustCompile(`(?m)^import java\.`), 3},
+	}},
+	{"javascript", []languageHint{
+		{regexp.MustCompile(`\bfunction\s*\w*\s*\(`), 1},
+		{regexp.MustCompile(`(?m)^\s*(const|let|var) \w+ = `), 1},
+		{regexp.MustCompile(`\) => `), 1},
+		{regexp.MustCompile(`\bconsole\.log\(`), 2},
+		{regexp.MustCompile(`\b(require|document\.getElementById)\(`), 1},
+	}},
+	{"sql", []languageHint{
+		{regexp.MustCompile(`(?is)\bselect\b.+\bfrom\b`), 2},
+		{regexp.MustCompile(`(?i)\b(insert into|create table|alter table|delete from)\b`), 2},
+		{regexp.MustCompile(`(?i)\b(where|group by|order by|join)\b`), 1},
+	}},
+	{"html", []languageHint{
+		{regexp.MustCompile(`(?i)<!doctype html`), 3},
+		{regexp.MustCompile(`(?i)<(html|head|body|div|span|p|a|ul|li|script)\b[^>]*>`), 1},
+		{regexp.MustCompile(`</\w+>`), 1},
+	}},
+	{"css", []languageHint{
+		{regexp.MustCompile(`(?m)^[.#]?[\w-]+( [.#]?[\w-]+)*\s*\{\s*$`), 1},
+		{regexp.MustCompile(`(?m)^\s*[\w-]+: [^;]+;\s*$`), 1},
+	}},
+}
+
+const minLanguageScore = 2
+
+// guessLanguage of a code block by a few patterns typical for popular languages
+func guessLanguage(code string) string {
+	if trimmed := strings.TrimSpace(code); (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	best, bestScore := "", minLanguageScore-1
+	for _, l := range languageHints {
+		score := 0
+		for _, h := range l.hints {
+			if h.re.MatchString(code) {
+				score += h.weight
+			}
+		}
+		if score > bestScore {
+			best, bestScore = l.lang, score
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,71 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+func TestConvert_Code(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		verbatim bool
+		want     string
+	}{
+		{
+			name:    "syntax language",
+			content: `<div style="--en-codeblock:true;--en-syntaxLanguage:TypeScript;"><div>let x = 1</div></div>`,
+			want:    "```typescript\nlet x = 1\n```\n",
+		},
+		{
+			name: "guessed language",
+			content: `<div style="-en-codeblock:true"><div>package main</div><div><br/></div>` +
+				`<div>func main() {</div><div>  fmt.Println("hi")</div><div>}</div></div>`,
+			want: "```go\npackage main\n\nfunc main() {\n  fmt.Println(\"hi\")\n}\n```\n",
+		},
+		{
+			name:    "json",
+			content: `<div style="-en-codeblock:true"><div>{"a": [1, 2]}</div></div>`,
+			want:    "```json\n{\"a\": [1, 2]}\n```\n",
+		},
+		{
+			name:     "default language",
+			content:  `<div style="-en-codeblock:true"><div>just words</div></div>`,
+			language: "text",
+			want:     "```text\njust words\n```\n",
+		},
+		{
+			name:     "plain text in Evernote",
+			content:  `<div style="--en-codeblock:true;--en-syntaxLanguage:plain text;"><div>echo not bash</div></div>`,
+			language: "text",
+			want:     "```\necho not bash\n```\n",
+		},
+		{
+			name:    "tidy",
+			content: "<div style=\"-en-codeblock:true\"><div><br/></div><div>\tindented  </div><div><br/></div><div><br/></div><div><br/></div><div>  end</div><div><br/></div></div>",
+			want:    "```\n\tindented\n\n  end\n```\n",
+		},
+		{
+			name:     "verbatim",
+			content:  "<div style=\"-en-codeblock:true\"><div>\tindented  </div><div><br/></div><div><br/></div><div><br/></div><div>  end</div></div>",
+			verbatim: true,
+			want:     "```\n\tindented  \n\n\n\n  end\n```\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := internal.NewConverter("", false, false, false)
+			c.CodeLanguage, c.VerbatimCode = tt.language, tt.verbatim
+			got, err := c.ConvertTo(&enex.Note{Title: "Code", Content: []byte(tt.content)}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "# Code\n\n" + tt.want; string(got.Content) != want {
+				t.Errorf("ConvertTo() = %q, want %q", got.Content, want)
+			}
+		})
+	}
+}
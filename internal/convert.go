@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"log"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,48 +16,62 @@ import (
 	"github.com/wormi4ok/evernote2md/encoding/markdown"
 )
 
-const FrontMatterTemplate = `---
-date: '{{.CTime}}'
-updated_at: '{{.MTime}}'
-title: {{ trim .Title | quote }}
-{{- if .TagList }}
-tags: [ {{ .TagList }} ]
-{{- end -}}
-{{- with .Attributes -}}
-{{- if .SourceUrl }}
-url: {{ trim .SourceUrl -}}
-{{- end -}}
-{{- if .Latitude }}
-latitude: {{ .Latitude -}}
-{{- end -}}
-{{- if .Longitude }}
-longitude: {{ .Longitude -}}
-{{- end -}}
-{{- if .Altitude }}
-altitude: {{ .Altitude -}}
-{{- end -}}
-{{- if .Source }}
-source: {{ trim .Source -}}
-{{- end }}
-{{- end }}
-
----
-
-`
-
 // Converter holds configuration options to control conversion
 type Converter struct {
-	TagTemplate         string
-	EnableHighlights    bool
-	EscapeSpecialChars  bool
-	EnableFrontMatter   bool
+	TagTemplate        string
+	EnableHighlights   bool
+	EscapeSpecialChars bool
+	EnableFrontMatter  bool
+	// FrontMatterFormat of the built-in front matter
+	FrontMatterFormat FrontMatterFormat
+	// FrontMatterTemplate replaces the built-in front matter, set it with SetFrontMatterTemplate
 	FrontMatterTemplate string
 
-	// err holds an error during conversion
-	// Every conversion step should check this field and skip execution if it is not empty
-	err error
+	// parsed FrontMatterTemplate
+	frontMatter     *template.Template
+	frontMatterText string
+
+	// Profile adjusts the output to a markdown application, set it with UseProfile
+	Profile Profile
+
+	// Passphrase to decrypt encrypted sections of notes
+	Passphrase string
+
+	// AttachmentsDir is a path relative to the output directory where attachments
+	// of all notes are stored once, named by their MD5 hash
+	AttachmentsDir string
+	// ReadableAttachmentNames keeps original names of attachments in AttachmentsDir
+	// adding a short hash to distinguish different files with the same name
+	ReadableAttachmentNames bool
+
+	// NoteLinks resolves Evernote note links, leaving them untouched if empty
+	NoteLinks *NoteIndex
+
+	// ResourceCheck collects attachments not matching their MD5 hash, they are not verified if empty
+	ResourceCheck *ResourceCheck
+	// StrictResources fails notes with corrupted attachments instead of saving them
+	StrictResources bool
+
+	// TextStyle defines if underline, subscript, superscript and text color are kept as inline HTML
+	TextStyle TextStyle
+	// HighlightStyle of highlighted text, the profile decides if empty
+	HighlightStyle markdown.HighlightStyle
+	// HighlightColors maps highlight colors to CSS classes or labels, see markdown.HighlightedText
+	HighlightColors map[string]string
+
+	// CodeLanguage of code blocks without a language set in Evernote or recognized from the content
+	CodeLanguage string
+	// VerbatimCode keeps code blocks exactly as they are, including trailing spaces and blank lines
+	VerbatimCode bool
+
+	// ObsidianTasks adds due dates and priorities to tasks in the format of Obsidian Tasks plugin
+	ObsidianTasks bool
 }
 
+// conversionStep is a part of the conversion process
+// Converter doesn't keep any state between steps to be safe for concurrent use
+type conversionStep func(note *enex.Note, md *markdown.Note) error
+
 // NewConverter creates a Converter with valid tagTemplate
 func NewConverter(tagTemplate string, enableFrontMatter, enableHighlights, escapeSpecialChars bool) (*Converter, error) {
 	if tagTemplate == "" {
@@ -69,60 +83,115 @@ func NewConverter(tagTemplate string, enableFrontMatter, enableHighlights, escap
 	}
 
 	return &Converter{
-		TagTemplate:         tagTemplate,
-		EnableHighlights:    enableHighlights,
-		EscapeSpecialChars:  escapeSpecialChars,
-		EnableFrontMatter:   enableFrontMatter,
-		FrontMatterTemplate: FrontMatterTemplate,
+		TagTemplate:        tagTemplate,
+		EnableHighlights:   enableHighlights,
+		EscapeSpecialChars: escapeSpecialChars,
+		EnableFrontMatter:  enableFrontMatter,
+		FrontMatterFormat:  YAML,
+		TextStyle:          TextHTML,
+		Profile:            DefaultProfile,
 	}, nil
 }
 
 // Convert an Evernote file to markdown
 func (c *Converter) Convert(note *enex.Note) (*markdown.Note, error) {
+	return c.ConvertTo(note, "")
+}
+
+// ConvertTo converts an Evernote file to markdown knowing the path where it will be saved
+// relative to the output directory, which is needed to link notes between each other
+func (c *Converter) ConvertTo(note *enex.Note, notePath string) (*markdown.Note, error) {
 	md := new(markdown.Note)
 	md.Media = map[string]markdown.Resource{}
 
-	c.mapResources(note, md)
-	c.normalizeHTML(note, md, NewReplacerMedia(md.Media), &Code{}, &ExtraDiv{}, &TextFormatter{}, &EmptyAnchor{}, &NormalizeTodo{})
-	c.toMarkdown(note, md)
-	c.prependTags(note, md)
-	c.prependTitle(note, md)
-	c.trimSpaces(note, md)
-	c.addDates(note, md)
-	if c.EnableFrontMatter {
-		c.addFrontMatter(note, md)
+	steps := []conversionStep{
+		c.mapResources,
+		c.addTaskGroups,
+		func(note *enex.Note, md *markdown.Note) error {
+			return c.normalizeHTML(note, md, c.replacers(note, md, notePath)...)
+		},
+		c.toMarkdown,
+	}
+	if c.Profile == LogseqProfile {
+		steps = append(steps, c.outline, c.addDates, func(note *enex.Note, md *markdown.Note) error {
+			return c.prependProperties(note, md, notePath)
+		}, c.trimSpaces)
+	} else {
+		steps = append(steps, c.prependTags, c.prependTitle, c.trimSpaces, c.addDates)
+		if c.EnableFrontMatter {
+			steps = append(steps, func(note *enex.Note, md *markdown.Note) error {
+				return c.addFrontMatter(note, md, notePath)
+			})
+		}
 	}
 
-	return md, c.err
+	for _, step := range steps {
+		if err := step(note, md); err != nil {
+			return nil, err
+		}
+	}
+
+	return md, nil
+}
+
+func (c *Converter) replacers(note *enex.Note, md *markdown.Note, notePath string) []TagReplacer {
+	media := NewReplacerMedia(md.Media)
+	media.warnings = md.Warn
+	if c.AttachmentsDir != "" {
+		media.Dir = relativePath(notePath, c.AttachmentsDir)
+	}
+	if c.Profile == ObsidianProfile {
+		media.WikiLinks, media.Root = true, path.Dir(notePath)
+	}
+	tasks := NewReplacerTasks(note.Tasks)
+	tasks.ObsidianTasks = c.ObsidianTasks
+	encrypted := NewReplacerEncrypted(c.Passphrase)
+	encrypted.warnings = md.Warn
+	rr := []TagReplacer{encrypted, media, tasks, &Code{DefaultLanguage: c.CodeLanguage, Verbatim: c.VerbatimCode}, &ExtraDiv{}, &TextFormatter{Style: c.TextStyle}, &EmptyAnchor{}, &NormalizeTodo{}}
+	if c.NoteLinks != nil {
+		link := NewReplacerNoteLink(c.NoteLinks, note.Title, notePath)
+		link.Notebook = note.Notebook
+		link.WikiLinks = c.wikiLinks()
+		link.PageNames = c.Profile == LogseqProfile
+		link.warnings = md.Warn
+		rr = append(rr, link)
+	}
+
+	return rr
 }
 
-func (c *Converter) mapResources(note *enex.Note, md *markdown.Note) {
+func (c *Converter) mapResources(note *enex.Note, md *markdown.Note) error {
 	names := map[string]int{}
 	r := note.Resources
 	for i := range r {
-		p, err := io.ReadAll(decoder(r[i].Data))
-		if c.err = err; err != nil {
-			return
+		hash, size, err := digest(r[i].Data)
+		if err != nil {
+			return err
 		}
 
-		rType := markdown.File
-		if isImage(r[i].Mime) {
-			rType = markdown.Image
-		}
+		rType := resourceType(r[i].Mime)
 		name, ext := name(r[i])
 
-		// Ensure the name is unique
-		if cnt, exist := names[name+ext]; exist {
+		if c.AttachmentsDir != "" {
+			// The same content always gets the same name in the shared directory
+			name = sharedName(name, hash, c.ReadableAttachmentNames)
+		} else if cnt, exist := names[name+ext]; exist {
+			// Ensure the name is unique
 			names[name+ext] = cnt + 1
 			name = fmt.Sprintf("%s-%d", name, cnt)
 		} else {
 			names[name+ext] = 1
 		}
 
+		if err := c.verifyResource(note, md, r[i], name+ext, hash); err != nil {
+			return err
+		}
+
 		mdr := markdown.Resource{
-			Name:    name + ext,
-			Type:    rType,
-			Content: p,
+			Name: name + ext,
+			Type: rType,
+			Open: r[i].Data.Open,
+			Size: size,
 		}
 
 		if r[i].ID != "" {
@@ -131,87 +200,112 @@ func (c *Converter) mapResources(note *enex.Note, md *markdown.Note) {
 			md.Media[strconv.Itoa(i)] = mdr
 		}
 	}
+
+	return nil
 }
 
-func (c *Converter) prependTitle(note *enex.Note, md *markdown.Note) {
-	if c.err != nil {
-		return
+func (c *Converter) verifyResource(note *enex.Note, md *markdown.Note, r enex.Resource, name string, hash string) error {
+	if (c.ResourceCheck == nil && !c.StrictResources) || !evernoteHash(note, r) {
+		return nil
+	}
+
+	err := verifyResource(r.ID, name, hash)
+	if err != nil && c.ResourceCheck != nil {
+		c.ResourceCheck.add(note.Title, err)
+	}
+	if c.StrictResources {
+		return err
 	}
+	if err != nil {
+		md.Warn("%s", err)
+	}
+
+	return nil
+}
 
+func (c *Converter) prependTitle(note *enex.Note, md *markdown.Note) error {
 	md.Content = append([]byte(fmt.Sprintf("# %s\n\n", note.Title)), md.Content...)
+
+	return nil
 }
 
-func (c *Converter) toMarkdown(note *enex.Note, md *markdown.Note) {
-	if c.err != nil {
-		return
-	}
+func (c *Converter) toMarkdown(note *enex.Note, md *markdown.Note) error {
 	var b bytes.Buffer
-	err := markdown.Convert(&b, bytes.NewReader(note.Content), c.EnableHighlights, c.EscapeSpecialChars)
-	if c.err = err; err != nil {
-		return
+	o := markdown.Options{
+		Highlights:         c.EnableHighlights,
+		HighlightStyle:     markdown.HighlightSpan,
+		EscapeSpecialChars: c.EscapeSpecialChars,
+		StyledText:         c.TextStyle != TextMarkdown,
+	}
+	switch c.Profile {
+	case ObsidianProfile:
+		o.HighlightStyle = markdown.HighlightEquals
+	case LogseqProfile:
+		o.TodoStyle = markdown.TodoKeyword
+	}
+	if c.HighlightStyle != "" {
+		o.HighlightStyle = c.HighlightStyle
+	}
+	o.HighlightColors = c.HighlightColors
+	err := markdown.Convert(&b, bytes.NewReader(note.Content), o)
+	if err != nil {
+		return err
 	}
 
 	md.Content = b.Bytes()
+
+	return nil
 }
 
-func (c *Converter) trimSpaces(_ *enex.Note, md *markdown.Note) {
-	if c.err != nil {
-		return
+func (c *Converter) trimSpaces(_ *enex.Note, md *markdown.Note) error {
+	if c.VerbatimCode {
+		md.Content = collapseBlankLines(md.Content)
+	} else {
+		md.Content = regexp.MustCompile(`\n{3,}`).ReplaceAllLiteral(md.Content, []byte("\n\n"))
 	}
-
-	md.Content = regexp.MustCompile(`\n{3,}`).ReplaceAllLiteral(md.Content, []byte("\n\n"))
 	md.Content = append(bytes.TrimRight(md.Content, "\n"), '\n')
+
+	return nil
 }
 
-func (c *Converter) addDates(note *enex.Note, md *markdown.Note) {
-	if c.err != nil {
-		return
+// collapseBlankLines leaves at most one blank line between paragraphs outside fenced code blocks
+func collapseBlankLines(content []byte) []byte {
+	var out bytes.Buffer
+	inCode, blank := false, 0
+	for line := range bytes.SplitAfterSeq(content, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimLeft(line, " \t-"), []byte("```")) {
+			inCode = !inCode
+		}
+		if !inCode && len(bytes.TrimRight(line, "\n")) == 0 {
+			if blank++; blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out.Write(line)
 	}
 
-	md.CTime = convertEvernoteDate(note.Created)
-	md.MTime = convertEvernoteDate(note.Updated)
+	return out.Bytes()
 }
 
-const dateFrontMatterFormat = "2006-01-02 15:04:05 -0700"
-
-func (c *Converter) addFrontMatter(note *enex.Note, md *markdown.Note) {
-	data := struct {
-		CTime      string
-		MTime      string
-		Title      string
-		Attributes enex.NoteAttributes
-		TagList    string
-	}{
-		md.CTime.Format(dateFrontMatterFormat),
-		md.MTime.Format(dateFrontMatterFormat),
-		note.Title,
-		note.Attributes,
-		c.tagList(note, "'{{tag}}'", ", ", false),
-	}
-	tmpl, err := template.New("frontMatter").Funcs(template.FuncMap{
-		"trim": func(text string) string {
-			return strings.TrimSpace(text)
-		},
-		"quote": func(text string) string {
-			return fmt.Sprintf("%q", text)
-		},
-	}).Parse(c.FrontMatterTemplate)
-	if err != nil {
-		panic(err)
-	}
-	var b bytes.Buffer
-	err = tmpl.Execute(&b, data)
-	if err != nil {
-		panic(err)
+func (c *Converter) addDates(note *enex.Note, md *markdown.Note) error {
+	for _, date := range []string{note.Created, note.Updated} {
+		if _, err := enex.ParseDate(date); date != "" && err != nil {
+			md.Warn("invalid date %s, using today instead", date)
+		}
 	}
-	md.Content = append(b.Bytes(), md.Content...)
+	md.CTime = convertEvernoteDate(note.Created)
+	md.MTime = convertEvernoteDate(note.Updated)
+
+	return nil
 }
 
-const evernoteDateFormat = "20060102T150405Z"
+const dateFrontMatterFormat = "2006-01-02 15:04:05 -0700"
 
 // 20180109T173725Z -> 2018-01-09T17:37:25Z
 func convertEvernoteDate(evernoteDate string) time.Time {
-	converted, err := time.Parse(evernoteDateFormat, evernoteDate)
+	converted, err := enex.ParseDate(evernoteDate)
 	if err != nil {
 		log.Printf("[DEBUG] Could not convert time /%s: %s, using today instead", evernoteDate, err.Error())
 		converted = time.Now()
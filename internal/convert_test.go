@@ -2,11 +2,15 @@ package internal_test
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/base64"
+	"encoding/hex"
 	"flag"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -88,19 +92,19 @@ func TestConvert(t *testing.T) {
 				MTime:   time.Date(2020, 12, 20, 22, 33, 44, 0, time.UTC),
 				Media: map[string]markdown.Resource{
 					"c9e6c70ea74388346ffa16ff8edbdf58": {
-						Name:    "1.jpg",
-						Type:    "image",
-						Content: image,
+						Name: "1.jpg",
+						Type: "image",
+						Size: int64(len(image)),
 					},
 					"90fdbde3hk91aff643883475tgh94bds1": {
-						Name:    "1-1.jpg",
-						Type:    "image",
-						Content: image,
+						Name: "1-1.jpg",
+						Type: "image",
+						Size: int64(len(image)),
 					},
 					"1sdb49hgt574388346ffa19kh3edbdf09": {
-						Name:    "complex?path=http-image-com-2.gif",
-						Type:    "image",
-						Content: image,
+						Name: "complex?path=http-image-com-2.gif",
+						Type: "image",
+						Size: int64(len(image)),
 					},
 				},
 			},
@@ -163,19 +167,19 @@ func TestConvert(t *testing.T) {
 				MTime:   time.Date(2020, 12, 20, 22, 33, 44, 0, time.UTC),
 				Media: map[string]markdown.Resource{
 					"c9e6c70ea74388346ffa16ff8edbdf58": {
-						Name:    "1.jpg",
-						Type:    "image",
-						Content: image,
+						Name: "1.jpg",
+						Type: "image",
+						Size: int64(len(image)),
 					},
 					"90fdbde3hk91aff643883475tgh94bds1": {
-						Name:    "1-1.jpg",
-						Type:    "image",
-						Content: image,
+						Name: "1-1.jpg",
+						Type: "image",
+						Size: int64(len(image)),
 					},
 					"1sdb49hgt574388346ffa19kh3edbdf09": {
-						Name:    "complex?path=http-image-com-2.gif",
-						Type:    "image",
-						Content: image,
+						Name: "complex?path=http-image-com-2.gif",
+						Type: "image",
+						Size: int64(len(image)),
 					},
 				},
 			},
@@ -199,6 +203,13 @@ func TestConvert(t *testing.T) {
 			}
 			content := goldenFile(t, tt.markdownFile)
 			tt.want.Content = content
+			for key, res := range got.Media {
+				if b := readResource(t, res); !bytes.Equal(b, image) {
+					t.Errorf("Convert() resource %s = %v, want %v", key, b, image)
+				}
+				res.Open = nil
+				got.Media[key] = res
+			}
 			if got != nil && !reflect.DeepEqual(got, tt.want) {
 				if !bytes.Equal(got.Content, tt.want.Content) {
 					dmp := diffmatchpatch.New()
@@ -222,13 +233,28 @@ func TestConvert(t *testing.T) {
 					}
 					t.Error(buff.String())
 				} else {
-					t.Errorf("Convert() = %s, want %+v", got.Media["c9e6c70ea74388346ffa16ff8edbdf58"].Content, tt.want)
+					t.Errorf("Convert() = %+v, want %+v", got.Media, tt.want.Media)
 				}
 			}
 		})
 	}
 }
 
+func readResource(t *testing.T, res markdown.Resource) []byte {
+	t.Helper()
+	r, err := res.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
 func goldenFile(t *testing.T, filename string) []byte {
 	golden := filepath.Join("testdata", filename)
 	expected, err := os.ReadFile(golden)
@@ -237,3 +263,149 @@ func goldenFile(t *testing.T, filename string) []byte {
 	}
 	return expected
 }
+
+func TestConvert_AttachmentsDir(t *testing.T) {
+	image, _ := base64.StdEncoding.DecodeString(encodedImage)
+	note := &enex.Note{
+		Title:   "Shared attachments",
+		Content: []byte(`<en-media type="image/gif" hash="c9e6c70ea74388346ffa16ff8edbdf58"/>`),
+		Resources: []enex.Resource{{
+			ID:         "c9e6c70ea74388346ffa16ff8edbdf58",
+			Mime:       "image/gif",
+			Attributes: enex.Attributes{Filename: "logo.gif"},
+			Data:       enex.Data{Encoding: "base64", Content: []byte(encodedImage)},
+		}},
+	}
+	sum := md5.Sum(image)
+	hash := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name     string
+		readable bool
+		want     string
+	}{
+		{"hash", false, "![" + hash + ".gif](../../attachments/" + hash + ".gif)"},
+		{"readable", true, "![logo-" + hash[:8] + ".gif](../../attachments/logo-" + hash[:8] + ".gif)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := internal.NewConverter("", false, false, false)
+			c.AttachmentsDir = "attachments"
+			c.ReadableAttachmentNames = tt.readable
+
+			got, err := c.ConvertTo(&enex.Note{Title: note.Title, Content: note.Content, Resources: note.Resources}, "Notebook/Shared_attachments/README.md")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(got.Content, []byte(tt.want)) {
+				t.Errorf("Convert() = %s, want to contain %s", got.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvert_MediaTypes(t *testing.T) {
+	resource := func(id, mime, name string) enex.Resource {
+		return enex.Resource{ID: id, Mime: mime, Attributes: enex.Attributes{Filename: name},
+			Data: enex.Data{Encoding: "base64", Content: []byte("c21hbGw=")}}
+	}
+	note := &enex.Note{
+		Title: "Media",
+		Content: []byte(`<en-media type="audio/mpeg" hash="a1"/><en-media type="video/mp4" hash="b2"/>` +
+			`<en-media type="application/pdf" hash="c3"/>`),
+		Resources: []enex.Resource{
+			resource("a1", "audio/mpeg", "song.mp3"),
+			resource("b2", "video/mp4", "clip.mp4"),
+			resource("c3", "application/pdf", "report.pdf"),
+		},
+	}
+	c, _ := internal.NewConverter("", false, false, false)
+	got, err := c.Convert(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<audio controls src="./audio/song.mp3"><a href="./audio/song.mp3">song.mp3</a></audio>`,
+		`<video controls src="./video/clip.mp4"><a href="./video/clip.mp4">clip.mp4</a></video>`,
+		`<object data="./pdf/report.pdf" type="application/pdf" width="100%" height="600"><a href="./pdf/report.pdf">report.pdf</a></object>`,
+	} {
+		if !bytes.Contains(got.Content, []byte(want)) {
+			t.Errorf("Convert() = %s, want to contain %s", got.Content, want)
+		}
+	}
+	for id, want := range map[string]markdown.ResourceType{"a1": markdown.Audio, "b2": markdown.Video, "c3": markdown.PDF} {
+		if got.Media[id].Type != want {
+			t.Errorf("Convert() resource %s type = %s, want %s", id, got.Media[id].Type, want)
+		}
+	}
+}
+
+func TestConvert_MissingMedia(t *testing.T) {
+	note := &enex.Note{
+		Title:   "Missing",
+		Content: []byte(`<div>before</div><en-media type="image/png" hash="0cc175b9c0f1b6a831c399e269772661"/><div>after</div>`),
+	}
+	c, _ := internal.NewConverter("", false, false, false)
+	got, err := c.Convert(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "# Missing\n\nbefore\nafter\n"; string(got.Content) != want {
+		t.Errorf("Convert() = %q, want %q", got.Content, want)
+	}
+	if len(got.Warnings) != 1 || !strings.Contains(got.Warnings[0], "0cc175b9c0f1b6a831c399e269772661 is missing") {
+		t.Errorf("Convert() warnings = %v, want the missing attachment", got.Warnings)
+	}
+}
+
+func TestConvert_ResourceCheck(t *testing.T) {
+	newNote := func(data string, content string) *enex.Note {
+		return &enex.Note{
+			Title:   "Truncated",
+			Content: []byte(content),
+			Resources: []enex.Resource{{
+				ID:         "13c9bea592733cd6dd5fbcc4e738ce99",
+				Mime:       "image/gif",
+				Attributes: enex.Attributes{Filename: "logo.gif"},
+				Data:       enex.Data{Encoding: "base64", Content: []byte(data)},
+			}},
+		}
+	}
+
+	const enMedia = `<en-media type="image/gif" hash="13c9bea592733cd6dd5fbcc4e738ce99"/>`
+	tests := []struct {
+		name    string
+		data    string
+		content string
+		strict  bool
+		wantErr bool
+		want    int
+	}{
+		{"intact", encodedImage, enMedia, false, false, 0},
+		{"intact strict", encodedImage, enMedia, true, false, 0},
+		{"truncated", encodedImage[:100], enMedia, false, false, 1},
+		{"truncated strict", encodedImage[:100], enMedia, true, true, 1},
+		{"hash from source url", encodedImage[:100], `<div>no reference</div>`, true, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := internal.NewConverter("", false, false, false)
+			c.ResourceCheck = internal.NewResourceCheck()
+			c.StrictResources = tt.strict
+
+			_, err := c.Convert(newNote(tt.data, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Convert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := c.ResourceCheck.Mismatches()
+			if len(got) != tt.want {
+				t.Fatalf("Mismatches() = %v, want %d", got, tt.want)
+			}
+			if tt.want > 0 && !strings.Contains(got[0], `"Truncated": attachment logo.gif is corrupted`) {
+				t.Errorf("Mismatches() = %v, want the note title and the attachment name", got)
+			}
+		})
+	}
+}
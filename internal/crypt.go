@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Evernote derives AES keys with PBKDF2-HMAC-SHA256
+const (
+	aesIterations = 50000
+	aesKeyLength  = 16
+	aesSaltLength = 16
+	aesMACLength  = sha256.Size
+	aesPrefix     = "ENC0"
+)
+
+var errWrongPassphrase = errors.New("wrong passphrase")
+
+// Encrypted replaces <en-crypt> sections with decrypted ENML,
+// which is converted along with the rest of the note.
+// Without a passphrase it leaves a placeholder with a hint instead.
+type Encrypted struct {
+	passphrase string
+
+	warnings warnFunc
+}
+
+// NewReplacerEncrypted creates an Encrypted TagReplacer to decrypt sections with a passphrase
+func NewReplacerEncrypted(passphrase string) *Encrypted {
+	return &Encrypted{passphrase: passphrase}
+}
+
+// ReplaceTag implements the TagReplacer interface
+func (r *Encrypted) ReplaceTag(n *html.Node) {
+	if n.Type != html.ElementNode || n.Data != "en-crypt" {
+		return
+	}
+
+	cipherName, hint := attr(n.Attr, "cipher"), attr(n.Attr, "hint")
+	content := strings.TrimSpace(textContent(n))
+
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		n.RemoveChild(c)
+	}
+	n.Data, n.DataAtom, n.Attr = "div", atom.Div, nil
+
+	if r.passphrase == "" {
+		appendPlaceholder(n, "Encrypted content", hint)
+		return
+	}
+
+	plain, err := decrypt(cipherName, content, r.passphrase)
+	if err != nil {
+		log.Printf("[WARN] Failed to decrypt an encrypted section: %s", err)
+		r.warnings.add("failed to decrypt an encrypted section: %s", err)
+		appendPlaceholder(n, "Encrypted content could not be decrypted", hint)
+		return
+	}
+
+	nodes, err := html.ParseFragment(bytes.NewReader(plain), n)
+	if err != nil {
+		log.Printf("[WARN] Failed to parse a decrypted section: %s", err)
+		r.warnings.add("failed to parse a decrypted section: %s", err)
+		appendPlaceholder(n, "Encrypted content could not be decrypted", hint)
+		return
+	}
+	for _, c := range nodes {
+		n.AppendChild(c)
+	}
+}
+
+func appendPlaceholder(n *html.Node, text, hint string) {
+	placeholder := "🔒 " + text
+	if hint != "" {
+		placeholder += fmt.Sprintf(" (hint: %s)", html.EscapeString(hint))
+	}
+	n.AppendChild(parseOne("<blockquote>"+placeholder+"</blockquote>", n))
+}
+
+// decrypt base64 content of <en-crypt> tag using the cipher from its attributes
+func decrypt(cipherName, content, passphrase string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, fmt.Errorf("decode encrypted data: %w", err)
+	}
+
+	switch strings.ToUpper(cipherName) {
+	case "AES":
+		return decryptAES(data, passphrase)
+	case "RC2", "":
+		// Sections created by the oldest Evernote clients have no cipher attribute
+		return decryptRC2(data, passphrase)
+	default:
+		return nil, fmt.Errorf("unsupported cipher %s", cipherName)
+	}
+}
+
+// decryptAES reads the data in the format used by Evernote since 2014:
+// "ENC0" + salt + HMAC salt + IV + AES-128-CBC ciphertext + HMAC-SHA256
+func decryptAES(data []byte, passphrase string) ([]byte, error) {
+	headerLength := len(aesPrefix) + 2*aesSaltLength + aes.BlockSize
+	if len(data) < headerLength+aesMACLength || string(data[:len(aesPrefix)]) != aesPrefix {
+		return nil, errors.New("unsupported format of AES encrypted data")
+	}
+
+	salt := data[len(aesPrefix) : len(aesPrefix)+aesSaltLength]
+	saltMAC := data[len(aesPrefix)+aesSaltLength : len(aesPrefix)+2*aesSaltLength]
+	iv := data[len(aesPrefix)+2*aesSaltLength : headerLength]
+	body, mac := data[:len(data)-aesMACLength], data[len(data)-aesMACLength:]
+	ciphertext := body[headerLength:]
+
+	macKey, err := pbkdf2.Key(sha256.New, passphrase, saltMAC, aesIterations, aesKeyLength)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, macKey)
+	h.Write(body)
+	if !hmac.Equal(h.Sum(nil), mac) {
+		return nil, errWrongPassphrase
+	}
+
+	if len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("AES encrypted data is not a multiple of the block size")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, aesIterations, aesKeyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, ciphertext)
+
+	return unpad(plain), nil
+}
+
+// decryptRC2 reads the legacy format: RC2 with a 64-bit key derived from MD5 of the passphrase.
+// Decrypted text starts with the first 4 hex digits of CRC32 of the rest of the text
+// and is padded with zero bytes.
+func decryptRC2(data []byte, passphrase string) ([]byte, error) {
+	if len(data) == 0 || len(data)%rc2BlockSize != 0 {
+		return nil, errors.New("RC2 encrypted data is not a multiple of the block size")
+	}
+
+	key := md5.Sum([]byte(passphrase))
+	block := newRC2(key[:], 64)
+	plain := make([]byte, len(data))
+	for i := 0; i < len(data); i += rc2BlockSize {
+		block.Decrypt(plain[i:], data[i:])
+	}
+
+	// There is no MAC in this format, a wrong key produces a text with a wrong checksum
+	plain = bytes.TrimRight(plain, "\x00")
+	if len(plain) < 4 || !strings.EqualFold(string(plain[:4]), rc2Checksum(plain[4:])) {
+		return nil, errWrongPassphrase
+	}
+
+	return plain[4:], nil
+}
+
+// rc2Checksum is the checksum Evernote puts in front of the text encrypted with RC2
+func rc2Checksum(text []byte) string {
+	return fmt.Sprintf("%08X", crc32.ChecksumIEEE(text))[:4]
+}
+
+// unpad removes PKCS#7 padding if present and trailing zero bytes otherwise
+func unpad(b []byte) []byte {
+	if n := len(b); n > 0 {
+		p := int(b[n-1])
+		if p > 0 && p <= aes.BlockSize && p <= n && bytes.Count(b[n-p:], b[n-1:]) == p {
+			return b[:n-p]
+		}
+	}
+
+	return bytes.TrimRight(b, "\x00")
+}
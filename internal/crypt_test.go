@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const secret = `<div>Secret <b>text</b></div>`
+
+func Test_rc2(t *testing.T) {
+	// Test vectors from RFC 2268
+	tests := []struct {
+		key, plain, want string
+		bits             int
+	}{
+		{"0000000000000000", "0000000000000000", "ebb773f993278eff", 63},
+		{"ffffffffffffffff", "ffffffffffffffff", "278b27e42e2f0d49", 64},
+		{"3000000000000000", "1000000000000001", "30649edf9be7d2c2", 64},
+		{"88", "0000000000000000", "61a8a244adacccf0", 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			key, _ := hex.DecodeString(tt.key)
+			plain, _ := hex.DecodeString(tt.plain)
+			c := newRC2(key, tt.bits)
+
+			got := make([]byte, rc2BlockSize)
+			c.Encrypt(got, plain)
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("Encrypt() = %x, want %s", got, tt.want)
+			}
+			c.Decrypt(got, got)
+			if !bytes.Equal(got, plain) {
+				t.Errorf("Decrypt() = %x, want %x", got, plain)
+			}
+		})
+	}
+}
+
+func Test_decrypt(t *testing.T) {
+	tests := []struct {
+		name       string
+		cipher     string
+		content    string
+		passphrase string
+		wantErr    bool
+	}{
+		{"AES", "AES", encryptAES(t, secret, "pass"), "pass", false},
+		{"AES wrong passphrase", "AES", encryptAES(t, secret, "pass"), "wrong", true},
+		{"RC2", "RC2", encryptRC2(secret, "pass"), "pass", false},
+		{"RC2 wrong passphrase", "RC2", encryptRC2(secret, "pass"), "wrong", true},
+		{"unknown cipher", "DES", encryptRC2(secret, "pass"), "pass", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decrypt(tt.cipher, tt.content, tt.passphrase)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != secret {
+				t.Errorf("decrypt() = %s, want %s", got, secret)
+			}
+		})
+	}
+}
+
+func TestEncrypted_ReplaceTag(t *testing.T) {
+	crypt := `<en-crypt cipher="AES" length="128" hint="my &amp; hint">` + encryptAES(t, secret, "pass") + `</en-crypt>`
+	tests := []struct {
+		name       string
+		passphrase string
+		want       string
+	}{
+		{"decrypted", "pass", "<div><div>Secret <b>text</b></div></div>"},
+		{"placeholder", "", "<div><blockquote>🔒 Encrypted content (hint: my &amp; hint)</blockquote></div>"},
+		{"wrong passphrase", "wrong", "<div><blockquote>🔒 Encrypted content could not be decrypted (hint: my &amp; hint)</blockquote></div>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, _ := html.Parse(strings.NewReader(crypt))
+			body := doc.FirstChild.LastChild
+			NewReplacerEncrypted(tt.passphrase).ReplaceTag(body.FirstChild)
+
+			var got bytes.Buffer
+			_ = html.Render(&got, body.FirstChild)
+			if got.String() != tt.want {
+				t.Errorf("ReplaceTag() = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func encryptAES(t *testing.T, plain, passphrase string) string {
+	salt, saltMAC, iv := bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 16), bytes.Repeat([]byte{3}, 16)
+	key, _ := pbkdf2.Key(sha256.New, passphrase, salt, aesIterations, aesKeyLength)
+	macKey, _ := pbkdf2.Key(sha256.New, passphrase, saltMAC, aesIterations, aesKeyLength)
+
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append([]byte(plain), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+
+	data := append([]byte(aesPrefix), salt...)
+	data = append(append(append(data, saltMAC...), iv...), padded...)
+	h := hmac.New(sha256.New, macKey)
+	h.Write(data)
+
+	return base64.StdEncoding.EncodeToString(h.Sum(data))
+}
+
+func encryptRC2(plain, passphrase string) string {
+	data := []byte(rc2Checksum([]byte(plain)) + plain)
+	if r := len(data) % rc2BlockSize; r > 0 {
+		data = append(data, make([]byte, rc2BlockSize-r)...)
+	}
+	key := md5.Sum([]byte(passphrase))
+	block := newRC2(key[:], 64)
+	for i := 0; i < len(data); i += rc2BlockSize {
+		block.Encrypt(data[i:], data[i:])
+	}
+
+	return base64.StdEncoding.EncodeToString(data)
+}
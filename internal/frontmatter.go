@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+)
+
+type (
+	// FrontMatter is the data model of the front matter template
+	FrontMatter struct {
+		Title string
+		// Notebook is named after the export file or the notebook mapping
+		Notebook string
+		// Path of the markdown file relative to the output directory
+		Path string
+
+		Created time.Time
+		Updated time.Time
+		// CTime and MTime are the creation and modification times formatted as 2006-01-02 15:04:05 -0700
+		CTime string
+		MTime string
+		// Dates from the note attributes, zero if not set
+		SubjectDate      time.Time
+		ReminderTime     time.Time
+		ReminderDoneTime time.Time
+
+		// Tags of the note adjusted to the profile
+		Tags []string
+		// TagList is a comma separated list of quoted tags
+		TagList string
+
+		Attributes enex.NoteAttributes
+		Resources  []FrontMatterResource
+	}
+
+	// FrontMatterResource describes an attachment of the note
+	FrontMatterResource struct {
+		// Name of the saved file
+		Name string
+		// Path to the saved file relative to the note
+		Path string
+		// Type is "image", "audio", "video", "pdf" or "file"
+		Type string
+		Mime string
+		// Hash is the MD5 hash Evernote uses to reference the resource
+		Hash   string
+		Size   int64
+		Width  int
+		Height int
+		// Filename is the original name of the file
+		Filename  string
+		SourceUrl string
+	}
+)
+
+// SetFrontMatterTemplate parses a template to render the front matter of every note instead of the built-in one
+// The template receives FrontMatter as data and can use functions:
+//
+//	date "2006-01-02" .Created  - format time using Go layout
+//	slugify .Title              - lowercase words joined with dashes
+//	yaml .Title                 - a quoted YAML scalar, or a flow sequence for lists
+//	json .Tags                  - JSON representation of any value
+//	trim .Title                 - remove leading and trailing spaces
+//	quote .Title                - a double-quoted string
+//	join ", " .Tags             - join a list of strings
+func (c *Converter) SetFrontMatterTemplate(text string) error {
+	tmpl, err := parseFrontMatter(text)
+	if err != nil {
+		return err
+	}
+	c.FrontMatterTemplate, c.frontMatterText, c.frontMatter = text, text, tmpl
+
+	return nil
+}
+
+func parseFrontMatter(text string) (*template.Template, error) {
+	tmpl, err := template.New("frontMatter").Funcs(template.FuncMap{
+		"date": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+		"slugify": slugify,
+		"yaml":    yamlValue,
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"trim": func(text string) string {
+			return strings.TrimSpace(text)
+		},
+		"quote": func(text string) string {
+			return fmt.Sprintf("%q", text)
+		},
+		"join": func(sep string, ss []string) string {
+			return strings.Join(ss, sep)
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse front matter template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+func (c *Converter) addFrontMatter(note *enex.Note, md *markdown.Note, notePath string) error {
+	if c.FrontMatterTemplate == "" {
+		md.Content = append(c.metadata(note, md).encode(c.FrontMatterFormat), md.Content...)
+		return nil
+	}
+
+	tmpl := c.frontMatter
+	if tmpl == nil || c.frontMatterText != c.FrontMatterTemplate {
+		// The template was changed without SetFrontMatterTemplate
+		var err error
+		if tmpl, err = parseFrontMatter(c.FrontMatterTemplate); err != nil {
+			return err
+		}
+	}
+
+	data := FrontMatter{
+		Title:      note.Title,
+		Notebook:   note.Notebook,
+		Path:       notePath,
+		Created:    md.CTime,
+		Updated:    md.MTime,
+		CTime:      md.CTime.Format(dateFrontMatterFormat),
+		MTime:      md.MTime.Format(dateFrontMatterFormat),
+		Tags:       c.tags(note, false),
+		TagList:    c.tagList(note, "'{{tag}}'", ", ", false),
+		Attributes: note.Attributes,
+	}
+	data.SubjectDate, _ = enex.ParseDate(note.Attributes.SubjectDate)
+	data.ReminderTime, _ = enex.ParseDate(note.Attributes.ReminderTime)
+	data.ReminderDoneTime, _ = enex.ParseDate(note.Attributes.ReminderDoneTime)
+	for i, r := range note.Resources {
+		key := r.ID
+		if key == "" {
+			key = strconv.Itoa(i)
+		}
+		res := md.Media[key]
+		dir := string(res.Type)
+		if c.AttachmentsDir != "" {
+			dir = relativePath(notePath, c.AttachmentsDir)
+		}
+		data.Resources = append(data.Resources, FrontMatterResource{
+			Name:      res.Name,
+			Path:      path.Join(dir, res.Name),
+			Type:      string(res.Type),
+			Mime:      r.Mime,
+			Hash:      r.ID,
+			Size:      res.Size,
+			Width:     r.Width,
+			Height:    r.Height,
+			Filename:  r.Attributes.Filename,
+			SourceUrl: r.Attributes.SourceUrl,
+		})
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return fmt.Errorf("front matter template: %w", err)
+	}
+	md.Content = append(b.Bytes(), md.Content...)
+
+	return nil
+}
+
+// slugify makes a URL-friendly name from the text
+func slugify(text string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	return sb.String()
+}
+
+// yamlValue formats a value to be safely inserted in YAML
+func yamlValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case nil:
+		return "null"
+	}
+
+	return fmt.Sprint(v)
+}
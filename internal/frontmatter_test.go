@@ -0,0 +1,209 @@
+package internal_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+func TestConvert_FrontMatterTemplate(t *testing.T) {
+	note := func() *enex.Note {
+		return &enex.Note{
+			Title:      ` Hello, "World"! `,
+			Notebook:   "Travel",
+			Created:    "20200102T030405Z",
+			Updated:    "20200203T040506Z",
+			Tags:       []string{"trip", "2020"},
+			Content:    []byte(`<en-media type="image/gif" hash="c9e6c70ea74388346ffa16ff8edbdf58"/>`),
+			Attributes: enex.NoteAttributes{Author: "Jane"},
+			Resources: []enex.Resource{{
+				ID:         "c9e6c70ea74388346ffa16ff8edbdf58",
+				Mime:       "image/gif",
+				Width:      16,
+				Height:     16,
+				Attributes: enex.Attributes{Filename: "logo.gif"},
+				Data:       enex.Data{Encoding: "base64", Content: []byte(encodedImage)},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "data and functions",
+			template: `---
+title: {{ yaml .Title }}
+slug: {{ slugify .Title }}
+date: {{ date "2006-01-02" .Created }}
+lastmod: {{ yaml .Updated }}
+notebook: {{ .Notebook }}
+tags: {{ yaml .Tags }}
+keywords: {{ join "; " .Tags }}
+author: {{ .Attributes.Author }}
+{{- range .Resources }}
+image: {{ json . }}
+{{- end }}
+---
+`,
+			want: `---
+title: " Hello, \"World\"! "
+slug: hello-world
+date: 2020-01-02
+lastmod: 2020-02-03T04:05:06Z
+notebook: Travel
+tags: ["trip", "2020"]
+keywords: trip; 2020
+author: Jane
+image: {"Name":"logo.gif","Path":"image/logo.gif","Type":"image","Mime":"image/gif","Hash":"c9e6c70ea74388346ffa16ff8edbdf58","Size":913,"Width":16,"Height":16,"Filename":"logo.gif","SourceUrl":""}
+---
+`,
+		},
+		{
+			name:     "execution error",
+			template: `{{ .Attributes.Unknown }}`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := internal.NewConverter("", true, false, false)
+			if err := c.SetFrontMatterTemplate(tt.template); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := c.ConvertTo(note(), "Hello_World.md")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertTo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !strings.HasPrefix(string(got.Content), tt.want) {
+				t.Errorf("ConvertTo() = %s, want to start with %s", got.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_SetFrontMatterTemplate(t *testing.T) {
+	c, _ := internal.NewConverter("", true, false, false)
+	if err := c.SetFrontMatterTemplate(`{{ .Title `); err == nil {
+		t.Error("SetFrontMatterTemplate() should fail to parse an invalid template")
+	}
+	if c.FrontMatterTemplate != "" {
+		t.Error("SetFrontMatterTemplate() should keep the previous template on error")
+	}
+}
+
+func TestConvert_FrontMatterFormat(t *testing.T) {
+	note := func() *enex.Note {
+		return &enex.Note{
+			Title:      "Q&A: \"quotes\" \\ and\ttabs",
+			Created:    "20200102T030405Z",
+			Updated:    "20200203T040506Z",
+			Tags:       []string{"a:b", "c"},
+			Attributes: enex.NoteAttributes{SourceUrl: "https://example.com/?q=a:b#c", Latitude: "52.50"},
+		}
+	}
+
+	tests := []struct {
+		format internal.FrontMatterFormat
+		want   string
+	}{
+		{internal.YAML, `---
+date: '2020-01-02 03:04:05 +0000'
+updated_at: '2020-02-03 04:05:06 +0000'
+title: "Q&A: \"quotes\" \\ and\ttabs"
+tags: [ 'a:b', 'c' ]
+url: https://example.com/?q=a:b#c
+latitude: 52.50
+
+---
+
+`},
+		{internal.TOML, `+++
+date = 2020-01-02T03:04:05Z
+updated_at = 2020-02-03T04:05:06Z
+title = "Q&A: \"quotes\" \\ and\ttabs"
+tags = ["a:b", "c"]
+url = "https://example.com/?q=a:b#c"
+latitude = 52.50
++++
+
+`},
+		{internal.JSON, `{
+  "date": "2020-01-02T03:04:05Z",
+  "updated_at": "2020-02-03T04:05:06Z",
+  "title": "Q&A: \"quotes\" \\ and\ttabs",
+  "tags": ["a:b", "c"],
+  "url": "https://example.com/?q=a:b#c",
+  "latitude": 52.50
+}
+
+`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			c, _ := internal.NewConverter("", true, false, false)
+			c.FrontMatterFormat = tt.format
+
+			got, err := c.Convert(note())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(got.Content), tt.want) {
+				t.Errorf("Convert() = %s, want to start with %s", got.Content, tt.want)
+			}
+			if tt.format == internal.JSON {
+				var v map[string]any
+				if err := json.Unmarshal([]byte(tt.want), &v); err != nil || v["title"] != note().Title {
+					t.Errorf("JSON front matter is invalid: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestConvert_FrontMatterReminder(t *testing.T) {
+	c, _ := internal.NewConverter("", true, false, false)
+	got, err := c.Convert(&enex.Note{
+		Title:   "Call the bank",
+		Created: "20201201T090000Z",
+		Updated: "20201202T090000Z",
+		Attributes: enex.NoteAttributes{
+			SubjectDate:   "20201130T000000Z",
+			PlaceName:     "Berlin",
+			ReminderOrder: "1606813200000",
+			ReminderTime:  "20201205T080000Z",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "place: Berlin\nsubject_date: '2020-11-30 00:00:00 +0000'\nreminder_order: 1606813200000\nreminder_time: '2020-12-05 08:00:00 +0000'\n\n---\n"
+	if !strings.Contains(string(got.Content), want) {
+		t.Errorf("Convert() = %s, want to contain %s", got.Content, want)
+	}
+}
+
+func TestConvert_FrontMatterUnsafeValues(t *testing.T) {
+	c, _ := internal.NewConverter("", true, false, false)
+	got, err := c.Convert(&enex.Note{
+		Title:      "Unsafe",
+		Tags:       []string{"it's"},
+		Attributes: enex.NoteAttributes{SourceUrl: "#anchor", Source: "web: clip", Author: "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "tags: [ 'it''s' ]\nurl: \"#anchor\"\nsource: \"web: clip\"\nauthor: \"true\"\n"
+	if !strings.Contains(string(got.Content), want) {
+		t.Errorf("Convert() = %s, want to contain %s", got.Content, want)
+	}
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+)
+
+// Evernote note links look like evernote:///view/<user>/<shard>/<guid>/<guid>/
+// or https://www.evernote.com/shard/<shard>/nl/<user>/<guid>/ when shared
+var reNoteLink = regexp.MustCompile(`^(?:evernote:///view/\d+/s\d+|https?://(?:www\.)?evernote\.com/shard/s\d+/nl/\d+)/([0-9a-fA-F-]{36})`)
+
+// NoteIndex keeps track of notes created during a run
+// to turn Evernote note links into relative links between markdown files
+// It is safe to resolve links concurrently
+type NoteIndex struct {
+	mu sync.Mutex
+
+	// notes by title, several notes in different notebooks may share a title
+	titles map[string][]indexedNote
+	guids  map[string]string
+	// original titles by note paths
+	pages map[string]string
+
+	// anchor texts of note links found in the export
+	links map[string][]string
+
+	unresolved []string
+}
+
+type indexedNote struct {
+	notebook string
+	path     string
+}
+
+// NewNoteIndex creates an empty index
+func NewNoteIndex() *NoteIndex {
+	return &NoteIndex{
+		titles: map[string][]indexedNote{},
+		guids:  map[string]string{},
+		pages:  map[string]string{},
+		links:  map[string][]string{},
+	}
+}
+
+// Add a note from a notebook to the index with a path relative to the output directory
+// If several notes share a title, links will point to the first one in the notebook
+// of the linking note, or to the first one in any notebook
+func (i *NoteIndex) Add(title, notebook, notePath string) {
+	key := indexKey(title)
+	i.titles[key] = append(i.titles[key], indexedNote{notebook: notebook, path: path.Clean(notePath)})
+	i.pages[path.Clean(notePath)] = title
+}
+
+// Collect note links from the note content to recover note identifiers
+//
+// Evernote exports don't contain note GUIDs, but the note links do.
+// A link text is usually a title of the note it points to,
+// so any link found in the export helps to find the note by its GUID
+func (i *NoteIndex) Collect(note *enex.Note) {
+	noteLinks(note.Content, func(guid, text string) {
+		i.links[guid] = append(i.links[guid], text)
+	})
+}
+
+// Targets returns paths of the notes linked from the note relative to the output directory
+// in the order of the links, paths of unresolved links are empty
+func (i *NoteIndex) Targets(note *enex.Note) []string {
+	var targets []string
+	noteLinks(note.Content, func(guid, text string) {
+		p, _ := i.Resolve(guid, text, note.Notebook, "")
+		targets = append(targets, p)
+	})
+
+	return targets
+}
+
+// noteLinks calls fn with the GUID and the text of every note link in the content
+func noteLinks(content []byte, fn func(guid, text string)) {
+	z := html.NewTokenizer(bytes.NewReader(content))
+	guid, text := "", new(strings.Builder)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken:
+			if t := z.Token(); t.DataAtom == atom.A {
+				guid = noteLinkGUID(attr(t.Attr, "href"))
+				text.Reset()
+			}
+		case html.TextToken:
+			if guid != "" {
+				text.Write(z.Text())
+			}
+		case html.EndTagToken:
+			if t := z.Token(); t.DataAtom == atom.A && guid != "" {
+				fn(guid, text.String())
+				guid = ""
+			}
+		}
+	}
+}
+
+// Resolve a note link from a note in the notebook to a path of the markdown file
+// relative to the note at path "from"
+func (i *NoteIndex) Resolve(guid, text, notebook, from string) (string, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if p, ok := i.guids[guid]; ok {
+		return relativePath(from, p), true
+	}
+
+	for _, t := range append([]string{text}, i.links[guid]...) {
+		notes := i.titles[indexKey(t)]
+		if len(notes) == 0 {
+			continue
+		}
+		if len(notes) == 1 {
+			// The title is unique, so the link always points to this note
+			i.guids[guid] = notes[0].path
+		}
+		p := notes[0].path
+		for _, n := range notes {
+			if n.notebook == notebook {
+				p = n.path
+				break
+			}
+		}
+		return relativePath(from, p), true
+	}
+
+	return "", false
+}
+
+// Unresolved returns descriptions of note links pointing to notes
+// that are missing in the converted export
+func (i *NoteIndex) Unresolved() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	unresolved := slices.Clone(i.unresolved)
+	sort.Strings(unresolved)
+
+	return unresolved
+}
+
+// title of the note saved at a given path
+func (i *NoteIndex) title(notePath string) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.pages[notePath]
+}
+
+func (i *NoteIndex) addUnresolved(title, text, guid string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.unresolved = append(i.unresolved, fmt.Sprintf(`"%s" links to "%s" (%s)`, title, text, guid))
+}
+
+// relativePath returns a slash-separated path to the target from the directory of the note
+func relativePath(from, target string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(from)), filepath.FromSlash(target))
+	if err != nil {
+		return target
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+func indexKey(title string) string {
+	return strings.ToLower(strings.TrimSpace(title))
+}
+
+func noteLinkGUID(href string) string {
+	if m := reNoteLink.FindStringSubmatch(href); len(m) > 1 {
+		return strings.ToLower(m[1])
+	}
+
+	return ""
+}
+
+func attr(aa []html.Attribute, key string) string {
+	for _, a := range aa {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
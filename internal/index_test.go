@@ -0,0 +1,81 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+const linkGUID = "0b4f3d6e-9b43-4a8e-9e1a-3c2b1a0f5d7e"
+
+func TestNoteIndex_Resolve(t *testing.T) {
+	index := internal.NewNoteIndex()
+	index.Add("Target note", "", "Target_note/README.md")
+	index.Collect(&enex.Note{
+		Content: []byte(`<div><a href="evernote:///view/123/s1/` + linkGUID + `/` + linkGUID + `/">Target <b>note</b></a></div>`),
+	})
+
+	tests := []struct {
+		name string
+		guid string
+		text string
+		want string
+		ok   bool
+	}{
+		{"by title", "00000000-0000-0000-0000-000000000001", "target note", "../Target_note/README.md", true},
+		{"by guid recovered from other links", linkGUID, "see here", "../Target_note/README.md", true},
+		{"missing note", "00000000-0000-0000-0000-000000000002", "Missing note", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := index.Resolve(tt.guid, tt.text, "", "Source_note/README.md")
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Resolve() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNoteIndex_ResolveNotebook(t *testing.T) {
+	index := internal.NewNoteIndex()
+	index.Add("Other Note", "Home Stuff", "Home_Stuff/Other_Note.md")
+	index.Add("Other Note", "Work", "Work/Other_Note.md")
+
+	for _, tt := range []struct{ notebook, from, want string }{
+		{"Work", "Work/My_Note.md", "Other_Note.md"},
+		{"Home Stuff", "Home_Stuff/My_Note.md", "Other_Note.md"},
+		{"Work", "Work/My_Note.md", "Other_Note.md"},
+		{"Travel", "Travel/My_Note.md", "../Home_Stuff/Other_Note.md"},
+	} {
+		if got, ok := index.Resolve(linkGUID, "Other Note", tt.notebook, tt.from); !ok || got != tt.want {
+			t.Errorf("Resolve() from %s = %v, %v, want %v", tt.notebook, got, ok, tt.want)
+		}
+	}
+}
+
+func TestConvert_NoteLinks(t *testing.T) {
+	c, _ := internal.NewConverter("", false, false, false)
+	c.NoteLinks = internal.NewNoteIndex()
+	c.NoteLinks.Add("Target note", "", "Target_note.md")
+
+	got, err := c.Convert(&enex.Note{
+		Title: "Source note",
+		Content: []byte(`<div><a href="evernote:///view/123/s1/` + linkGUID + `/` + linkGUID + `/">Target note</a></div>` +
+			`<div><a href="https://www.evernote.com/shard/s1/nl/123/00000000-0000-0000-0000-00000000000A/">Missing note</a></div>`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "[Target note](Target_note.md)"; !strings.Contains(string(got.Content), want) {
+		t.Errorf("Convert() = %s, want to contain %s", got.Content, want)
+	}
+	if unresolved := c.NoteLinks.Unresolved(); len(unresolved) != 1 || !strings.Contains(unresolved[0], "Missing note") {
+		t.Errorf("Unresolved() = %v, want a link to Missing note", unresolved)
+	}
+	if len(got.Warnings) != 1 || !strings.Contains(got.Warnings[0], "Missing note") {
+		t.Errorf("Convert() warnings = %v, want the unresolved link", got.Warnings)
+	}
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+)
+
+var reMD5 = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// ResourceCheck collects attachments which content doesn't match the MD5 hash
+// Evernote references them with, e.g. when the export was truncated
+// It is safe to use concurrently
+type ResourceCheck struct {
+	mu sync.Mutex
+
+	mismatches []string
+}
+
+// NewResourceCheck creates an empty ResourceCheck
+func NewResourceCheck() *ResourceCheck {
+	return &ResourceCheck{}
+}
+
+// Mismatches returns descriptions of corrupted attachments grouped by note
+func (rc *ResourceCheck) Mismatches() []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	mismatches := slices.Clone(rc.mismatches)
+	sort.Strings(mismatches)
+
+	return mismatches
+}
+
+func (rc *ResourceCheck) add(title string, err error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.mismatches = append(rc.mismatches, fmt.Sprintf(`"%s": %s`, title, err))
+}
+
+// verifyResource compares the MD5 hash of the content with the hash of the resource
+// Resources without a valid MD5 hash can't be verified and are considered intact
+func verifyResource(hash, name string, got string) error {
+	if !reMD5.MatchString(hash) {
+		return nil
+	}
+
+	if got != hash {
+		return fmt.Errorf("attachment %s is corrupted: MD5 hash %s doesn't match %s", name, got, hash)
+	}
+
+	return nil
+}
+
+// evernoteHash reports whether the ID of the resource is a hash Evernote keeps for the content:
+// the object ID of the recognition data or the hash the note content references it with
+// An ID taken from the source URL of the resource may be any hash, so it can't be verified
+func evernoteHash(note *enex.Note, r enex.Resource) bool {
+	if r.ID == "" {
+		return false
+	}
+
+	return len(r.Recognition) > 0 ||
+		bytes.Contains(note.Content, []byte(`hash="`+r.ID+`"`)) ||
+		bytes.Contains(note.Content, []byte(`hash='`+r.ID+`'`))
+}
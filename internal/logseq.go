@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+)
+
+// Directories of a Logseq graph
+const (
+	LogseqPagesDir    = "pages"
+	LogseqJournalsDir = "journals"
+	LogseqAssetsDir   = "assets"
+)
+
+// journalFormats are common titles of daily notes
+var journalFormats = []string{
+	"2006-01-02",
+	"2006_01_02",
+	"2006/01/02",
+	"2006.01.02",
+	"02.01.2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"Monday, January 2, 2006",
+	"Mon, Jan 2, 2006",
+	"2 January 2006",
+}
+
+// JournalDate parses the title of a daily note, which Logseq keeps in journals
+func JournalDate(title string) (time.Time, bool) {
+	for _, layout := range journalFormats {
+		if t, err := time.Parse(layout, strings.TrimSpace(title)); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// LogseqJournalName is a file name of the journal page for a date
+func LogseqJournalName(date time.Time) string {
+	return date.Format("2006_01_02") + ".md"
+}
+
+// logseqPageName is a name Logseq uses to link to the note saved at a given path
+// Journal pages are named after the date in the default Logseq format, e.g. "Jan 2nd, 2006"
+func logseqPageName(title, notePath string) string {
+	if path.Dir(notePath) != LogseqJournalsDir {
+		return title
+	}
+	date, ok := JournalDate(title)
+	if !ok {
+		return title
+	}
+
+	suffix := "th"
+	switch date.Day() {
+	case 1, 21, 31:
+		suffix = "st"
+	case 2, 22:
+		suffix = "nd"
+	case 3, 23:
+		suffix = "rd"
+	}
+
+	return fmt.Sprintf("%s %d%s, %d", date.Format("Jan"), date.Day(), suffix, date.Year())
+}
+
+var (
+	reListItem = regexp.MustCompile(`^( *)(?:[-*+]|(\d+)[.)]) (.*)$`)
+	reTodo     = regexp.MustCompile(`^(TODO|DONE) `)
+	newlines   = regexp.MustCompile(`\s*\n\s*`)
+)
+
+// godown indents nested lists with 4 spaces
+const listIndent = 4
+
+// outline turns markdown paragraphs into Logseq blocks
+//
+// Every paragraph becomes a block, items of nested lists become nested blocks,
+// and other lines of a paragraph are indented to stay in the same block
+func (c *Converter) outline(_ *enex.Note, md *markdown.Note) error {
+	var out bytes.Buffer
+	indent, start, fence := "", true, false
+	for _, line := range strings.Split(string(md.Content), "\n") {
+		m := reListItem.FindStringSubmatch(line)
+		switch {
+		case fence && line == "":
+			// Code blocks may contain empty lines
+			out.WriteString("\n")
+		case fence:
+			out.WriteString(indent + "  " + line + "\n")
+		case strings.TrimSpace(line) == "":
+			start = true
+			continue
+		case m != nil:
+			indent = strings.Repeat("\t", len(m[1])/listIndent)
+			out.WriteString(indent + "- " + m[3] + "\n")
+			if m[2] != "" {
+				out.WriteString(indent + "  logseq.order-list-type:: number\n")
+			}
+		case start || reTodo.MatchString(line):
+			indent = ""
+			out.WriteString("- " + line + "\n")
+		default:
+			out.WriteString(indent + "  " + line + "\n")
+		}
+		start = false
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fence = !fence
+		}
+	}
+	md.Content = out.Bytes()
+
+	return nil
+}
+
+// prependProperties adds Logseq page properties instead of the front matter
+func (c *Converter) prependProperties(note *enex.Note, md *markdown.Note, notePath string) error {
+	var b bytes.Buffer
+	property := func(key, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			_, _ = fmt.Fprintf(&b, "%s:: %s\n", key, newlines.ReplaceAllString(value, " "))
+		}
+	}
+
+	// Journal pages are named after the date
+	if path.Dir(notePath) != LogseqJournalsDir {
+		property("title", note.Title)
+	}
+	property("tags", strings.Join(c.tags(note, false), ", "))
+	property("created", md.CTime.Format(dateLogseqFormat))
+	property("updated", md.MTime.Format(dateLogseqFormat))
+	property("author", note.Attributes.Author)
+	property("source", note.Attributes.SourceUrl)
+
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	md.Content = append(b.Bytes(), md.Content...)
+
+	return nil
+}
+
+const dateLogseqFormat = "2006-01-02 15:04"
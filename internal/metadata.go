@@ -0,0 +1,425 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+)
+
+// FrontMatterFormat is a serialisation format of the front matter
+type FrontMatterFormat string
+
+const (
+	// YAML front matter is enclosed in --- lines
+	YAML FrontMatterFormat = "yaml"
+	// TOML front matter is enclosed in +++ lines
+	TOML FrontMatterFormat = "toml"
+	// JSON front matter is an object at the beginning of the file
+	JSON FrontMatterFormat = "json"
+)
+
+// ParseFrontMatterFormat returns a format by name, empty name means YAML
+func ParseFrontMatterFormat(name string) (FrontMatterFormat, error) {
+	switch f := FrontMatterFormat(strings.ToLower(name)); f {
+	case "":
+		return YAML, nil
+	case YAML, TOML, JSON:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("unknown front matter format %q, supported formats: yaml, toml, json", name)
+}
+
+type (
+	// metadata is an ordered list of front matter fields
+	metadata struct {
+		fields []metaField
+		// padded YAML has an empty line before the closing ---, like the original front matter
+		padded bool
+	}
+
+	metaField struct {
+		key   string
+		value any
+	}
+
+	// localDateTime is a date and time without a time zone
+	localDateTime time.Time
+	// plainString is written without quotes in YAML unless it needs them
+	plainString string
+	// numeric is a number written as it is in the note attributes
+	numeric string
+	// quotedList is written as a flow sequence of single-quoted strings in YAML
+	quotedList []string
+)
+
+// add a field unless the value is empty
+func (m *metadata) add(key string, value any) {
+	switch v := value.(type) {
+	case string:
+		if v = strings.TrimSpace(v); v == "" {
+			return
+		}
+		value = v
+	case plainString:
+		if v = plainString(strings.TrimSpace(string(v))); v == "" {
+			return
+		}
+		value = v
+	case []string:
+		if len(v) == 0 {
+			return
+		}
+	case quotedList:
+		if len(v) == 0 {
+			return
+		}
+	}
+	m.fields = append(m.fields, metaField{key, value})
+}
+
+// number keeps a numeric attribute as it is, or as a string if it is not a valid number in all formats
+func number(s string) any {
+	s = strings.TrimSpace(s)
+	if _, err := strconv.ParseFloat(s, 64); err == nil && json.Valid([]byte(s)) {
+		return numeric(s)
+	}
+
+	return s
+}
+
+// addDate adds an optional date attribute unless it is missing or invalid
+// Local dates are written without a time zone
+func (m *metadata) addDate(key, date string, local bool) {
+	t, err := enex.ParseDate(date)
+	switch {
+	case err != nil:
+		return
+	case local:
+		m.add(key, localDateTime(t))
+	default:
+		m.add(key, t)
+	}
+}
+
+// metadata of the note in the front matter depends on the profile
+func (c *Converter) metadata(note *enex.Note, md *markdown.Note) metadata {
+	var m metadata
+	a := note.Attributes
+
+	if c.Profile == ObsidianProfile {
+		// Obsidian properties
+		m.add("title", note.Title)
+		m.add("tags", c.tags(note, false))
+		m.add("created", localDateTime(md.CTime))
+		m.add("updated", localDateTime(md.MTime))
+		m.add("author", a.Author)
+		m.add("source", a.SourceUrl)
+		if a.Latitude != "" && a.Longitude != "" {
+			m.add("location", []any{number(a.Latitude), number(a.Longitude)})
+		}
+		m.add("place", a.PlaceName)
+		m.addDate("reminder", a.ReminderTime, true)
+		m.addDate("reminder_done", a.ReminderDoneTime, true)
+
+		return m
+	}
+
+	m.padded = true
+	m.add("date", md.CTime)
+	m.add("updated_at", md.MTime)
+	m.add("title", note.Title)
+	m.add("tags", quotedList(c.tags(note, false)))
+	m.add("url", plainString(a.SourceUrl))
+	if a.Latitude != "" {
+		m.add("latitude", number(a.Latitude))
+	}
+	if a.Longitude != "" {
+		m.add("longitude", number(a.Longitude))
+	}
+	if a.Altitude != "" {
+		m.add("altitude", number(a.Altitude))
+	}
+	m.add("source", plainString(a.Source))
+	m.add("author", plainString(a.Author))
+	m.add("place", plainString(a.PlaceName))
+	m.addDate("subject_date", a.SubjectDate, false)
+	if a.ReminderOrder != "" {
+		m.add("reminder_order", number(a.ReminderOrder))
+	}
+	m.addDate("reminder_time", a.ReminderTime, false)
+	m.addDate("reminder_done_time", a.ReminderDoneTime, false)
+
+	return m
+}
+
+// encode the metadata as a front matter block followed by an empty line
+func (m metadata) encode(format FrontMatterFormat) []byte {
+	var b bytes.Buffer
+	switch format {
+	case JSON:
+		b.WriteString("{\n")
+		for i, f := range m.fields {
+			b.WriteString("  " + quoteString(f.key) + ": " + encodeValue(f.value, JSON))
+			if i < len(m.fields)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("}\n\n")
+	case TOML:
+		b.WriteString("+++\n")
+		for _, f := range m.fields {
+			b.WriteString(f.key + " = " + encodeValue(f.value, TOML) + "\n")
+		}
+		b.WriteString("+++\n\n")
+	default:
+		b.WriteString("---\n")
+		for _, f := range m.fields {
+			b.WriteString(f.key + ": " + encodeValue(f.value, YAML) + "\n")
+		}
+		if m.padded {
+			b.WriteString("\n")
+		}
+		b.WriteString("---\n\n")
+	}
+
+	return b.Bytes()
+}
+
+// encodeValue in a form which is the same in all formats where possible:
+// double-quoted strings, numbers and inline arrays
+func encodeValue(value any, format FrontMatterFormat) string {
+	switch v := value.(type) {
+	case string:
+		return quoteString(v)
+	case plainString:
+		if format == YAML && isPlainYAML(string(v)) {
+			return string(v)
+		}
+		return quoteString(string(v))
+	case numeric:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		// Dates are native types in TOML, YAML keeps the format of the original front matter
+		switch format {
+		case JSON:
+			return quoteString(v.Format(time.RFC3339))
+		case YAML:
+			return "'" + v.Format(dateFrontMatterFormat) + "'"
+		}
+		return v.Format(time.RFC3339)
+	case localDateTime:
+		if format == JSON {
+			return quoteString(time.Time(v).Format("2006-01-02T15:04:05"))
+		}
+		return time.Time(v).Format("2006-01-02T15:04:05")
+	case quotedList:
+		if format == YAML && !strings.ContainsFunc(strings.Join(v, ""), unicode.IsControl) {
+			values := make([]string, len(v))
+			for i := range v {
+				values[i] = "'" + strings.ReplaceAll(v[i], "'", "''") + "'"
+			}
+			return "[ " + strings.Join(values, ", ") + " ]"
+		}
+		return encodeValue([]string(v), format)
+	case []string:
+		values := make([]any, len(v))
+		for i := range v {
+			values[i] = v[i]
+		}
+		return encodeValue(values, format)
+	case []any:
+		values := make([]string, len(v))
+		for i := range v {
+			values[i] = encodeValue(v[i], format)
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	}
+
+	return quoteString(fmt.Sprint(value))
+}
+
+// isPlainYAML reports whether the string is read back the same without quotes
+func isPlainYAML(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsFunc(s, unicode.IsControl) ||
+		strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off", ".inf", "-.inf", ".nan":
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+
+	return err != nil
+}
+
+// quoteString escapes a string so that it is valid in YAML, TOML and JSON
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				_, _ = fmt.Fprintf(&b, `\u%04x`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// parseMetadata reads the front matter written by the converter, or in a similar form,
+// from the beginning of the content and returns the fields and the rest of the content
+// Values are strings or lists of strings
+func parseMetadata(content []byte) (map[string]any, []byte) {
+	fields := map[string]any{}
+	switch {
+	case bytes.HasPrefix(content, []byte("{")):
+		dec := json.NewDecoder(bytes.NewReader(content))
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			return fields, content
+		}
+		for k, v := range m {
+			fields[k] = jsonField(v)
+		}
+		return fields, content[dec.InputOffset():]
+	case bytes.HasPrefix(content, []byte("---\n")), bytes.HasPrefix(content, []byte("+++\n")):
+	default:
+		return fields, content
+	}
+
+	fence := string(content[:3])
+	lines := strings.Split(string(content), "\n")
+	var list string
+	for i, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		if line == fence {
+			return fields, []byte(strings.Join(lines[i+2:], "\n"))
+		}
+		if item, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok && list != "" {
+			// A YAML block sequence
+			l, _ := fields[list].([]string)
+			fields[list] = append(l, metaValue(item).(string))
+			continue
+		}
+
+		sep := ":"
+		if fence == "+++" {
+			sep = "="
+		}
+		key, value, ok := strings.Cut(line, sep)
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		list = ""
+		if value == "" {
+			list = key
+			continue
+		}
+		fields[key] = metaValue(value)
+	}
+
+	// The front matter doesn't end
+	return map[string]any{}, content
+}
+
+// metaValue parses a scalar or an inline list
+func metaValue(value string) any {
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		var items []string
+		for _, item := range splitList(value[1 : len(value)-1]) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, metaValue(item).(string))
+			}
+		}
+		return items
+	}
+
+	switch {
+	case len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"':
+		var s string
+		if err := json.Unmarshal([]byte(value), &s); err == nil {
+			return s
+		}
+		return value[1 : len(value)-1]
+	case len(value) > 1 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+
+	return value
+}
+
+// splitList splits items of an inline list by commas outside of quotes
+func splitList(s string) []string {
+	var items []string
+	var quote rune
+	start, escaped := 0, false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(items, s[start:])
+}
+
+// jsonField converts a JSON value to a string or a list of strings
+func jsonField(v any) any {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(jsonField(item)))
+		}
+		return items
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Profile adjusts the output to a particular markdown application
+type Profile string
+
+const (
+	// DefaultProfile produces markdown readable by most editors
+	DefaultProfile Profile = "default"
+	// ObsidianProfile produces an Obsidian vault with wikilinks, embeds and properties
+	ObsidianProfile Profile = "obsidian"
+	// LogseqProfile produces a Logseq graph with outlined pages, journals and shared assets
+	LogseqProfile Profile = "logseq"
+)
+
+// Profiles lists all supported profiles
+var Profiles = []Profile{DefaultProfile, ObsidianProfile, LogseqProfile}
+
+// ParseProfile returns a profile by name, empty name means the default profile
+func ParseProfile(name string) (Profile, error) {
+	if name == "" {
+		return DefaultProfile, nil
+	}
+	for _, p := range Profiles {
+		if string(p) == strings.ToLower(name) {
+			return p, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown profile %q, supported profiles: %s", name, profileNames())
+}
+
+func profileNames() string {
+	names := make([]string, len(Profiles))
+	for i, p := range Profiles {
+		names[i] = string(p)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// UseProfile configures the converter for the profile
+// A custom tag template takes precedence over the one defined by the profile
+func (c *Converter) UseProfile(p Profile) {
+	c.Profile = p
+	switch p {
+	case ObsidianProfile:
+		if c.TagTemplate == DefaultTagTemplate {
+			c.TagTemplate = "#" + tagToken
+		}
+	case LogseqProfile:
+		// Logseq expects all attachments in one directory
+		c.AttachmentsDir = LogseqAssetsDir
+		c.ReadableAttachmentNames = true
+	}
+}
+
+// wikiLinks reports whether the profile links notes with [[wikilinks]]
+func (c *Converter) wikiLinks() bool {
+	return c.Profile == ObsidianProfile || c.Profile == LogseqProfile
+}
+
+// obsidianTag makes a tag valid in Obsidian, which allows only letters, digits,
+// underscores, dashes and slashes for nested tags, and requires a non-numeric character
+func obsidianTag(t string) string {
+	t = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '_', r == '-', r == '/':
+			return r
+		case unicode.IsSpace(r):
+			return '_'
+		}
+		return '-'
+	}, spaces.ReplaceAllString(strings.TrimLeft(strings.TrimSpace(t), "#"), " "))
+
+	if t != "" && strings.IndexFunc(t, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+		t = "_" + t
+	}
+
+	return t
+}
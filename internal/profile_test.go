@@ -0,0 +1,171 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    internal.Profile
+		wantErr bool
+	}{
+		{"", internal.DefaultProfile, false},
+		{"default", internal.DefaultProfile, false},
+		{"Obsidian", internal.ObsidianProfile, false},
+		{"logseq", internal.LogseqProfile, false},
+		{"notion", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := internal.ParseProfile(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseProfile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvert_ObsidianProfile(t *testing.T) {
+	c, _ := internal.NewConverter("", true, true, false)
+	c.UseProfile(internal.ObsidianProfile)
+	c.NoteLinks = internal.NewNoteIndex()
+	c.NoteLinks.Add("Target note", "", "Projects/Target note.md")
+
+	got, err := c.ConvertTo(&enex.Note{
+		Title:   "Source note",
+		Created: "20200102T030405Z",
+		Updated: "20200203T040506Z",
+		Tags:    []string{"Work/Project Alpha", "2020", "c++"},
+		Content: []byte(`<div><a href="evernote:///view/123/s1/` + linkGUID + `/` + linkGUID + `/">Target note</a></div>` +
+			`<div><span style="--en-highlight:yellow;-evernote-highlight:true;">Important</span></div>` +
+			`<en-media type="image/gif" hash="c9e6c70ea74388346ffa16ff8edbdf58"/>` +
+			`<en-media type="application/pdf" hash="d41d8cd98f00b204e9800998ecf8427e"/>`),
+		Attributes: enex.NoteAttributes{Author: "Jane", SourceUrl: "https://example.com", Latitude: "52.5", Longitude: "13.4"},
+		Resources: []enex.Resource{{
+			ID:   "c9e6c70ea74388346ffa16ff8edbdf58",
+			Mime: "image/gif",
+			Data: enex.Data{Encoding: "base64", Content: []byte(encodedImage)},
+		}, {
+			ID:         "d41d8cd98f00b204e9800998ecf8427e",
+			Mime:       "application/pdf",
+			Attributes: enex.Attributes{Filename: "report.pdf"},
+			Data:       enex.Data{Encoding: "base64"},
+		}},
+	}, "Projects/Source note.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"---\ntitle: \"Source note\"\ntags: [\"Work/Project_Alpha\", \"_2020\", \"c--\"]\n" +
+			"created: 2020-01-02T03:04:05\nupdated: 2020-02-03T04:05:06\n" +
+			"author: \"Jane\"\nsource: \"https://example.com\"\nlocation: [52.5, 13.4]\n---\n",
+		"#Work/Project_Alpha #_2020 #c--",
+		"[[Projects/Target note|Target note]]",
+		"==Important==",
+		"![[Projects/image/c9e6c70ea74388346ffa16ff8edbdf58.gif]]",
+		"![[Projects/pdf/report.pdf]]",
+	} {
+		if !strings.Contains(string(got.Content), want) {
+			t.Errorf("ConvertTo() = %s, want to contain %s", got.Content, want)
+		}
+	}
+}
+
+func TestConvert_ObsidianProfile_HTMLTable(t *testing.T) {
+	c, _ := internal.NewConverter("", false, true, false)
+	c.UseProfile(internal.ObsidianProfile)
+	c.NoteLinks = internal.NewNoteIndex()
+	c.NoteLinks.Add("Other Note", "", "Home_Stuff/Other_Note.md")
+
+	got, err := c.ConvertTo(&enex.Note{
+		Title: "My Note",
+		Content: []byte(`<table><tr><td colspan="2"><a href="evernote:///view/123/s1/` + linkGUID + `/` + linkGUID + `/">Other Note</a></td></tr>` +
+			`<tr><td><en-media type="audio/mpeg" hash="d41d8cd98f00b204e9800998ecf8427e"/></td><td>clip</td></tr></table>`),
+		Resources: []enex.Resource{{
+			ID:         "d41d8cd98f00b204e9800998ecf8427e",
+			Mime:       "audio/mpeg",
+			Attributes: enex.Attributes{Filename: "clip.mp3"},
+			Data:       enex.Data{Encoding: "base64"},
+		}},
+	}, "Work/My_Note.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<a href="../Home_Stuff/Other_Note.md">Other Note</a>`,
+		`<audio controls src="audio/clip.mp3"><a href="audio/clip.mp3">clip.mp3</a></audio>`,
+	} {
+		if !strings.Contains(string(got.Content), want) {
+			t.Errorf("ConvertTo() = %s, want to contain %s", got.Content, want)
+		}
+	}
+}
+
+func TestConvert_LogseqProfile(t *testing.T) {
+	c, _ := internal.NewConverter("", true, false, false)
+	c.UseProfile(internal.LogseqProfile)
+	c.NoteLinks = internal.NewNoteIndex()
+	c.NoteLinks.Add("Target note", "", "pages/Target_note.md")
+	c.NoteLinks.Add("2021-03-04", "", "journals/2021_03_04.md")
+
+	got, err := c.ConvertTo(&enex.Note{
+		Title:   "Source note",
+		Created: "20200102T030405Z",
+		Updated: "20200203T040506Z",
+		Tags:    []string{"work", "one, two"},
+		Content: []byte(`<div><a href="evernote:///view/123/s1/` + linkGUID + `/` + linkGUID + `/">Target note</a></div>` +
+			`<div><a href="evernote:///view/123/s1/00000000-0000-0000-0000-00000000000B/00000000-0000-0000-0000-00000000000B/">2021-03-04</a></div>` +
+			`<table><tr><td>a</td><td>b</td></tr></table>` +
+			`<ul><li>item<ul><li>nested</li></ul></li></ul>` +
+			`<ol><li>first</li></ol>` +
+			`<div><en-todo checked="true"/>done</div><div><en-todo/>todo</div>` +
+			`<div style="-en-codeblock:true"><pre>code
+
+more code</pre></div>` +
+			`<en-media type="image/gif" hash="c9e6c70ea74388346ffa16ff8edbdf58"/>`),
+		Resources: []enex.Resource{{
+			ID:         "c9e6c70ea74388346ffa16ff8edbdf58",
+			Mime:       "image/gif",
+			Attributes: enex.Attributes{Filename: "logo.gif"},
+			Data:       enex.Data{Encoding: "base64", Content: []byte(encodedImage)},
+		}},
+	}, "pages/Source_note.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "title:: Source note\n" +
+		"tags:: work, one two\n" +
+		"created:: 2020-01-02 03:04\n" +
+		"updated:: 2020-02-03 04:05\n" +
+		"\n" +
+		"- [[Target note]]\n" +
+		"- [[Mar 4th, 2021]]\n" +
+		"- | a   | b   |\n" +
+		"  | --- | --- |\n" +
+		"- item\n" +
+		"\t- nested\n" +
+		"- first\n" +
+		"  logseq.order-list-type:: number\n" +
+		"- DONE done\n" +
+		"- TODO todo\n" +
+		"- ```\n" +
+		"  code\n" +
+		"\n" +
+		"  more code\n" +
+		"  ```\n" +
+		"- ![logo-13c9bea5.gif](../assets/logo-13c9bea5.gif)\n"
+	if string(got.Content) != want {
+		t.Errorf("ConvertTo() = %s, want %s", got.Content, want)
+	}
+}
@@ -3,11 +3,11 @@ package internal
 import (
 	"bytes"
 	"fmt"
+	"path"
 	"strconv"
 	"strings"
 
 	"golang.org/x/net/html"
-	"golang.org/x/net/html/atom"
 
 	"github.com/wormi4ok/evernote2md/encoding/enex"
 	"github.com/wormi4ok/evernote2md/encoding/markdown"
@@ -19,13 +19,10 @@ type TagReplacer interface {
 	ReplaceTag(node *html.Node)
 }
 
-func (c *Converter) normalizeHTML(note *enex.Note, _ *markdown.Note, rr ...TagReplacer) {
-	if c.err != nil {
-		return
-	}
+func (c *Converter) normalizeHTML(note *enex.Note, _ *markdown.Note, rr ...TagReplacer) error {
 	doc, err := html.Parse(bytes.NewReader(note.Content))
-	if c.err = err; err != nil {
-		return
+	if err != nil {
+		return err
 	}
 	var f func(*html.Node)
 	f = func(n *html.Node) {
@@ -39,25 +36,60 @@ func (c *Converter) normalizeHTML(note *enex.Note, _ *markdown.Note, rr ...TagRe
 	f(doc)
 
 	var out bytes.Buffer
-	if c.err = html.Render(&out, doc); c.err != nil {
-		return
+	if err := html.Render(&out, doc); err != nil {
+		return err
 	}
 	note.Content = out.Bytes()
+
+	return nil
+}
+
+// warnFunc reports a problem with the note to the conversion result, it does nothing if empty
+type warnFunc func(format string, args ...any)
+
+func (w warnFunc) add(format string, args ...any) {
+	if w != nil {
+		w(format, args...)
+	}
 }
 
 // Media tag replacer puts a standard HTML <img> tag
-// instead of custom <en-media> tag if it is an image
+// instead of custom <en-media> tag if it is an image, <audio>, <video>
+// or <object> tag for audio, video and PDF files,
 // and <a> tag for everything else to be able to download it as a file
 type Media struct {
 	resources map[string]markdown.Resource
 
+	// Dir overrides a relative path to the resources, which is a directory named after resource type by default
+	Dir string
+
+	// WikiLinks embeds images with ![[wikilinks]] and links other files with [[wikilinks]]
+	// Wikilinks are resolved from the output directory, so Root is the directory of the note in it
+	WikiLinks bool
+	Root      string
+
 	// If identifiers are missing we use resources one by one
 	cnt int
+
+	warnings warnFunc
 }
 
+// htmlFormat of every resource type, audio, video and PDF tags are rendered by markdown.EmbeddedMedia rule
 var htmlFormat = map[markdown.ResourceType]string{
 	markdown.Image: `<img src="%s/%s" alt="%s" />`,
 	markdown.File:  `<a href="./%s/%s">%s</a>`,
+	markdown.Audio: `<audio controls="" src="./%s/%s" title="%s"></audio>`,
+	markdown.Video: `<video controls="" src="./%s/%s" title="%s"></video>`,
+	markdown.PDF:   `<object data="./%s/%s" type="application/pdf" width="100%%" height="600" title="%s"></object>`,
+}
+
+// wikiFormat is rendered by markdown.WikiLink rule, the link relative to the note is used where wikilinks don't work
+var wikiFormat = map[markdown.ResourceType]string{
+	markdown.Image: `<wikilink href="%s" link="%s" type="image" embed="true"></wikilink>`,
+	markdown.File:  `<wikilink href="%s" link="%s"></wikilink>`,
+	markdown.Audio: `<wikilink href="%s" link="%s" type="audio" embed="true"></wikilink>`,
+	markdown.Video: `<wikilink href="%s" link="%s" type="video" embed="true"></wikilink>`,
+	markdown.PDF:   `<wikilink href="%s" link="%s" type="pdf" embed="true"></wikilink>`,
 }
 
 // NewReplacerMedia creates a Media TagReplacer using resources as a data source
@@ -69,11 +101,16 @@ func NewReplacerMedia(resources map[string]markdown.Resource) *Media {
 func (r *Media) ReplaceTag(n *html.Node) {
 	if isMedia(n) {
 		if res, ok := r.resources[hashAttr(n)]; ok {
-			replaceNode(n, res)
+			r.replaceNode(n, res)
 			return
 		}
-		replaceNode(n, r.resources[strconv.Itoa(r.cnt)])
+		res, ok := r.resources[strconv.Itoa(r.cnt)]
 		r.cnt++
+		if !ok {
+			r.warnings.add("attachment %s is missing in the export", hashAttr(n))
+			return
+		}
+		r.replaceNode(n, res)
 	}
 }
 
@@ -91,8 +128,20 @@ func hashAttr(n *html.Node) string {
 	return ""
 }
 
-func replaceNode(n *html.Node, res markdown.Resource) {
-	appendMedia(n, parseOne(resourceReference(res), n))
+func (r *Media) replaceNode(n *html.Node, res markdown.Resource) {
+	dir := r.Dir
+	if dir == "" {
+		dir = string(res.Type)
+	}
+	ref := resourceReference(dir, res)
+	if r.WikiLinks {
+		ref = wikiReference(r.Root, dir, res)
+	}
+	if ref == "" {
+		r.warnings.add("attachment %s has an unknown type %q", res.Name, res.Type)
+		return
+	}
+	appendMedia(n, parseOne(ref, n))
 }
 
 func appendMedia(node, media *html.Node) {
@@ -111,42 +160,25 @@ func parseOne(h string, context *html.Node) *html.Node {
 	return nodes[0]
 }
 
-func resourceReference(res markdown.Resource) string {
-	return fmt.Sprintf(htmlFormat[res.Type], res.Type, res.Name, res.Name)
-}
-
-// Code replaces div tag stylized to look like code blocks with an actual <pre> tag
-type Code struct{}
-
-// ReplaceTag implements the TagReplacer interface
-func (r *Code) ReplaceTag(n *html.Node) {
-	if isCode(n) {
-		var f func(*html.Node)
-		f = func(n *html.Node) {
-			if n.Type == html.ElementNode && n.Data == "div" {
-				p := n.Parent
-				p.InsertBefore(parseOne("\n", p), n.NextSibling)
-			}
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				f(c)
-			}
-		}
-		f(n)
-		n.Data = "pre"
-		n.DataAtom = atom.Pre
+// resourceReference to the resource in a directory relative to the note, empty if the type is unknown
+func resourceReference(dir string, res markdown.Resource) string {
+	format, ok := htmlFormat[res.Type]
+	if !ok {
+		return ""
 	}
+
+	return fmt.Sprintf(format, dir, res.Name, res.Name)
 }
 
-func isCode(n *html.Node) bool {
-	if n.Type == html.ElementNode && n.Data == "div" {
-		for _, a := range n.Attr {
-			if a.Key == "style" {
-				return strings.Contains(a.Val, "-en-codeblock:true")
-			}
-		}
+// wikiReference to the resource in a directory relative to the note, which is saved in root directory
+func wikiReference(root, dir string, res markdown.Resource) string {
+	format, ok := wikiFormat[res.Type]
+	if !ok {
+		return ""
 	}
 
-	return false
+	return fmt.Sprintf(format,
+		html.EscapeString(path.Join(root, dir, res.Name)), html.EscapeString(path.Join(dir, res.Name)))
 }
 
 // ExtraDiv removes extra line break in tables and lists
@@ -181,54 +213,96 @@ func hasExtraDiv(n *html.Node) bool {
 	return false
 }
 
-// TextFormatter catches bold and italic, bold takes precedence
-type TextFormatter struct{}
+// EmptyAnchor removes anchor tags without text
+type EmptyAnchor struct{}
 
 // ReplaceTag implements the TagReplacer interface
-func (*TextFormatter) ReplaceTag(n *html.Node) {
-	if isBold(n) {
-		n.Data = "strong"
-		n.Attr = []html.Attribute{}
-	} else if isItalic(n) {
-		n.Data = "i"
-		n.Attr = []html.Attribute{}
+func (*EmptyAnchor) ReplaceTag(n *html.Node) {
+	if n.Type == html.ElementNode && n.Data == "a" {
+		if content := n.FirstChild; content == nil {
+			n.Data = "span"
+		}
 	}
 }
 
-func isBold(n *html.Node) bool {
-	if n.Type == html.ElementNode && n.Data == "span" {
-		for _, a := range n.Attr {
-			if a.Key == "style" {
-				return strings.Contains(a.Val, "font-weight: bold")
-			}
-		}
-	}
+// NoteLink replaces Evernote note links with relative links to converted notes
+type NoteLink struct {
+	index *NoteIndex
+	title string
+	path  string
 
-	return false
+	// Notebook of the note, links to titles shared by several notes prefer the notes in it
+	Notebook string
+	// WikiLinks replaces note links with [[wikilinks]] resolved from the output directory
+	WikiLinks bool
+	// PageNames links notes by Logseq page names instead of paths
+	PageNames bool
+
+	warnings warnFunc
 }
 
-func isItalic(n *html.Node) bool {
-	if n.Type == html.ElementNode && n.Data == "span" {
-		for _, a := range n.Attr {
-			if a.Key == "style" {
-				return strings.Contains(a.Val, "font-style: italic")
+// NewReplacerNoteLink creates a NoteLink TagReplacer for the note
+// with a given title saved at a given path relative to the output directory
+func NewReplacerNoteLink(index *NoteIndex, title, path string) *NoteLink {
+	return &NoteLink{index: index, title: title, path: path}
+}
+
+// ReplaceTag implements the TagReplacer interface
+func (r *NoteLink) ReplaceTag(n *html.Node) {
+	if n.Type != html.ElementNode || n.Data != "a" {
+		return
+	}
+	for i, a := range n.Attr {
+		if a.Key != "href" {
+			continue
+		}
+		guid := noteLinkGUID(a.Val)
+		if guid == "" {
+			return
+		}
+		text := textContent(n)
+		from := r.path
+		if r.WikiLinks {
+			from = "" // resolve from the output directory
+		}
+		p, ok := r.index.Resolve(guid, text, r.Notebook, from)
+		switch {
+		case !ok:
+			r.index.addUnresolved(r.title, text, guid)
+			r.warnings.add("unresolved note link %q (%s)", text, guid)
+		case r.PageNames:
+			// Logseq doesn't support aliases in wikilinks
+			n.Data, n.DataAtom = "wikilink", 0
+			n.Attr = []html.Attribute{
+				{Key: "href", Val: logseqPageName(r.index.title(p), p)},
+				{Key: "link", Val: relativePath(r.path, p)},
+			}
+			for c := n.FirstChild; c != nil; c = n.FirstChild {
+				n.RemoveChild(c)
+			}
+		case r.WikiLinks:
+			n.Data, n.DataAtom = "wikilink", 0
+			n.Attr = []html.Attribute{
+				{Key: "href", Val: strings.TrimSuffix(p, ".md")},
+				{Key: "link", Val: relativePath(r.path, p)},
 			}
+		default:
+			n.Attr[i].Val = p
 		}
+		return
 	}
-
-	return false
 }
 
-// EmptyAnchor removes anchor tags without text
-type EmptyAnchor struct{}
-
-// ReplaceTag implements the TagReplacer interface
-func (*EmptyAnchor) ReplaceTag(n *html.Node) {
-	if n.Type == html.ElementNode && n.Data == "a" {
-		if content := n.FirstChild; content == nil {
-			n.Data = "span"
-		}
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
 	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+
+	return sb.String()
 }
 
 // NormalizeTodo replaces style-based checkboxes with tag-based checkboxes.
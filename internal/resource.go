@@ -1,8 +1,8 @@
 package internal
 
 import (
-	"bytes"
-	"encoding/base64"
+	"crypto/md5"
+	"encoding/hex"
 	"io"
 	"mime"
 	"path"
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
 	"github.com/wormi4ok/evernote2md/file"
 )
 
@@ -17,24 +18,53 @@ var reImg = regexp.MustCompile(`^image/[\w]+`)
 
 var reFileAndExt = regexp.MustCompile(`(.*)(\.[\w\d]+)`)
 
-var reBase64 = regexp.MustCompile(`^([A-Za-z0-9+/]{4})*([A-Za-z0-9+/]{3}=|[A-Za-z0-9+/]{2}==)?$`)
-
-func decoder(d enex.Data) io.Reader {
-	if d.Encoding == "base64" || isBase64Encoded(d.Content) {
-		return base64.NewDecoder(base64.StdEncoding, bytes.NewReader(bytes.TrimSpace(d.Content)))
+// sharedName of an attachment stored once for all notes is its MD5 hash,
+// optionally prefixed with the original name for readability
+func sharedName(name string, hash string, readable bool) string {
+	if readable {
+		return name + "-" + hash[:8]
 	}
 
-	return bytes.NewReader(d.Content)
+	return hash
 }
 
-func isBase64Encoded(content []byte) bool {
-	return reBase64.Match(content)
+// digest reads the data of the resource to get its MD5 hash and size
+// without keeping the content in memory
+func digest(d enex.Data) (hash string, size int64, err error) {
+	r, err := d.Open()
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = r.Close() }()
+
+	h := md5.New()
+	if size, err = io.Copy(h, r); err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), size, nil
 }
 
 func isImage(mimeType string) bool {
 	return reImg.MatchString(mimeType)
 }
 
+// resourceType decides how to show the resource in markdown and where to save it
+func resourceType(mimeType string) markdown.ResourceType {
+	switch {
+	case isImage(mimeType):
+		return markdown.Image
+	case strings.HasPrefix(mimeType, "audio/"):
+		return markdown.Audio
+	case strings.HasPrefix(mimeType, "video/"):
+		return markdown.Video
+	case mimeType == "application/pdf":
+		return markdown.PDF
+	}
+
+	return markdown.File
+}
+
 func name(r enex.Resource) (name string, extension string) {
 	name = guessName(r)
 	// Try to split a file into name and extension
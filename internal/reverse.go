@@ -0,0 +1,392 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	rhtml "github.com/yuin/goldmark/renderer/html"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+)
+
+// Reverse converts markdown notes with their attachments back to Evernote notes
+type Reverse struct {
+	// TagTemplate recognises the line of tags under the title when there is no front matter
+	TagTemplate string
+
+	md goldmark.Markdown
+}
+
+// NewReverse creates a Reverse converter for notes with tags formatted by tagTemplate
+func NewReverse(tagTemplate string) *Reverse {
+	if tagTemplate == "" {
+		tagTemplate = DefaultTagTemplate
+	}
+
+	return &Reverse{
+		TagTemplate: tagTemplate,
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.GFM),
+			// Inline HTML produced by the converter, like highlights, is kept and sanitised later
+			goldmark.WithRendererOptions(rhtml.WithUnsafe()),
+		),
+	}
+}
+
+// Convert a markdown file to a note
+// Local images and links to files next to the note become resources of the note,
+// modTime is used when the front matter has no dates
+func (r *Reverse) Convert(content []byte, notePath string, modTime time.Time) (*enex.Note, error) {
+	fields, body := parseMetadata(content)
+	note := &enex.Note{
+		Title:      field(fields, "title"),
+		Tags:       fieldList(fields, "tags"),
+		Attributes: noteAttributes(fields),
+	}
+	note.Created = enexDate(firstField(fields, "date", "created"), modTime)
+	note.Updated = enexDate(firstField(fields, "updated_at", "updated"), modTime)
+
+	body = r.stripHeader(note, body)
+	if note.Title == "" {
+		note.Title = strings.TrimSuffix(filepath.Base(notePath), filepath.Ext(notePath))
+	}
+
+	var b bytes.Buffer
+	if err := r.md.Convert(body, &b); err != nil {
+		return nil, fmt.Errorf("convert markdown: %w", err)
+	}
+
+	root := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(&b, root)
+	if err != nil {
+		return nil, fmt.Errorf("parse html: %w", err)
+	}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	enml := &enmlWriter{dir: filepath.Dir(notePath), hashes: map[string]bool{}, note: note}
+	for c := root.FirstChild; c != nil; {
+		next := c.NextSibling
+		enml.sanitize(c)
+		c = next
+	}
+	if enml.err != nil {
+		return nil, enml.err
+	}
+	var out bytes.Buffer
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&out, c); err != nil {
+			return nil, err
+		}
+	}
+	note.Content = out.Bytes()
+
+	return note, nil
+}
+
+// stripHeader removes the title and the tags the converter prepends to the content
+func (r *Reverse) stripHeader(note *enex.Note, body []byte) []byte {
+	rest := strings.TrimLeft(string(body), "\r\n")
+	line, next, _ := strings.Cut(rest, "\n")
+	if title, ok := strings.CutPrefix(strings.TrimSpace(line), "# "); ok {
+		title = unescapeMarkdown(strings.TrimSpace(title))
+		if note.Title == "" {
+			note.Title = title
+		}
+		if note.Title == title {
+			rest = strings.TrimLeft(next, "\r\n")
+		}
+	}
+
+	line, next, _ = strings.Cut(rest, "\n")
+	if tags := r.parseTags(strings.TrimSpace(line)); tags != nil && (note.Tags == nil || strings.Join(tags, ",") == strings.Join(note.Tags, ",")) {
+		note.Tags = tags
+		rest = next
+	}
+
+	return []byte(rest)
+}
+
+// parseTags returns tags if the line contains only tags formatted with TagTemplate
+func (r *Reverse) parseTags(line string) []string {
+	prefix, suffix, _ := strings.Cut(r.TagTemplate, tagToken)
+	tag := regexp.QuoteMeta(prefix) + `(.+?)` + regexp.QuoteMeta(suffix)
+	if suffix == "" {
+		tag = regexp.QuoteMeta(prefix) + `(\S+)`
+	}
+	if line == "" || !regexp.MustCompile(`^(?:`+tag+`\s*)+$`).MatchString(line) {
+		return nil
+	}
+
+	var tags []string
+	for _, m := range regexp.MustCompile(tag).FindAllStringSubmatch(line, -1) {
+		tags = append(tags, m[1])
+	}
+
+	return tags
+}
+
+var reEscaped = regexp.MustCompile("\\\\([!-/:-@\\[-`{-~])")
+
+func unescapeMarkdown(s string) string {
+	return reEscaped.ReplaceAllString(s, "$1")
+}
+
+func field(fields map[string]any, key string) string {
+	switch v := fields[key].(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ", ")
+	}
+
+	return ""
+}
+
+func firstField(fields map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if v := field(fields, key); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func fieldList(fields map[string]any, key string) []string {
+	switch v := fields[key].(type) {
+	case []string:
+		return v
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	}
+
+	return nil
+}
+
+// noteAttributes maps front matter of the default and Obsidian profiles to the note attributes
+func noteAttributes(fields map[string]any) enex.NoteAttributes {
+	a := enex.NoteAttributes{
+		SubjectDate:      enexDate(field(fields, "subject_date"), time.Time{}),
+		Latitude:         field(fields, "latitude"),
+		Longitude:        field(fields, "longitude"),
+		Altitude:         field(fields, "altitude"),
+		Author:           field(fields, "author"),
+		SourceUrl:        field(fields, "url"),
+		ReminderOrder:    field(fields, "reminder_order"),
+		ReminderTime:     enexDate(firstField(fields, "reminder_time", "reminder"), time.Time{}),
+		ReminderDoneTime: enexDate(firstField(fields, "reminder_done_time", "reminder_done"), time.Time{}),
+		PlaceName:        field(fields, "place"),
+	}
+	// Obsidian profile keeps the URL as the source
+	if source := field(fields, "source"); strings.Contains(source, "://") {
+		a.SourceUrl = source
+	} else {
+		a.Source = source
+	}
+	if location := fieldList(fields, "location"); len(location) == 2 {
+		a.Latitude, a.Longitude = location[0], location[1]
+	}
+
+	return a
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	dateFrontMatterFormat,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	dateLogseqFormat,
+	"2006-01-02",
+}
+
+// enexDate converts a date from the front matter, dates without time zone are local
+func enexDate(date string, fallback time.Time) string {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(date), time.Local); err == nil {
+			return t.UTC().Format(enex.DateFormat)
+		}
+	}
+	if fallback.IsZero() {
+		return ""
+	}
+
+	return fallback.UTC().Format(enex.DateFormat)
+}
+
+// enmlWriter turns HTML into ENML, a subset of XHTML Evernote notes are written in
+type enmlWriter struct {
+	dir    string
+	note   *enex.Note
+	hashes map[string]bool
+	err    error
+}
+
+// enmlElements are allowed in ENML, other elements are replaced with their content
+var enmlElements = map[string]bool{
+	"a": true, "abbr": true, "acronym": true, "address": true, "area": true, "b": true, "bdo": true, "big": true,
+	"blockquote": true, "br": true, "caption": true, "center": true, "cite": true, "code": true, "col": true,
+	"colgroup": true, "dd": true, "del": true, "dfn": true, "div": true, "dl": true, "dt": true, "em": true,
+	"font": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "i": true,
+	"img": true, "ins": true, "kbd": true, "li": true, "map": true, "ol": true, "p": true, "pre": true, "q": true,
+	"s": true, "samp": true, "small": true, "span": true, "strike": true, "strong": true, "sub": true, "sup": true,
+	"table": true, "tbody": true, "td": true, "tfoot": true, "th": true, "thead": true, "tr": true, "tt": true,
+	"u": true, "ul": true, "var": true, "xmp": true,
+	"en-media": true, "en-todo": true, "en-crypt": true,
+}
+
+// enmlDropped are removed together with their content
+var enmlDropped = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "form": true, "button": true,
+	"select": true, "textarea": true, "noscript": true,
+}
+
+var enmlAttributes = regexp.MustCompile(`^(id|class|accesskey|data|dynsrc|tabindex|on.*)$`)
+
+func (w *enmlWriter) sanitize(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		w.sanitize(c)
+		c = next
+	}
+	if n.Type != html.ElementNode {
+		return
+	}
+
+	switch n.Data {
+	case "img":
+		if p, ok := w.localFile(attr(n.Attr, "src")); ok {
+			w.media(n, p)
+		}
+	case "a":
+		href := attr(n.Attr, "href")
+		if p, ok := w.localFile(href); ok && !strings.EqualFold(filepath.Ext(p), ".md") {
+			w.media(n, p)
+		} else if ok || strings.HasSuffix(strings.ToLower(href), ".md") {
+			// Links between markdown files can't be restored as note links
+			unwrap(n)
+			return
+		}
+	case "input":
+		if attr(n.Attr, "type") == "checkbox" {
+			n.Data, n.DataAtom = "en-todo", 0
+			n.Attr = []html.Attribute{{Key: "checked", Val: fmt.Sprint(hasAttr(n, "checked"))}}
+			if next := n.NextSibling; next != nil && next.Type == html.TextNode {
+				next.Data = strings.TrimPrefix(next.Data, " ")
+			}
+		}
+	case "p", "li":
+		todo(n)
+	}
+
+	switch {
+	case enmlDropped[n.Data]:
+		n.Parent.RemoveChild(n)
+	case !enmlElements[n.Data]:
+		unwrap(n)
+	default:
+		attrs := n.Attr[:0]
+		for _, a := range n.Attr {
+			if !enmlAttributes.MatchString(strings.ToLower(a.Key)) {
+				attrs = append(attrs, a)
+			}
+		}
+		n.Attr = attrs
+	}
+}
+
+// localFile returns a path to an existing file referenced relative to the note
+func (w *enmlWriter) localFile(ref string) (string, bool) {
+	u, err := url.Parse(ref)
+	if ref == "" || err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || filepath.IsAbs(u.Path) {
+		return "", false
+	}
+	p := filepath.Join(w.dir, filepath.FromSlash(u.Path))
+	if info, err := os.Stat(p); err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+
+	return p, true
+}
+
+// media replaces the node with a reference to the file attached to the note
+func (w *enmlWriter) media(n *html.Node, p string) {
+	data := enex.FileData(p)
+	hash, _, err := digest(data)
+	if err != nil {
+		w.err = fmt.Errorf("read attachment %s: %w", p, err)
+		return
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(p)))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if !w.hashes[hash] {
+		w.hashes[hash] = true
+		w.note.Resources = append(w.note.Resources, enex.Resource{
+			ID:         hash,
+			Mime:       mimeType,
+			Data:       data,
+			Attributes: enex.Attributes{Filename: filepath.Base(p)},
+		})
+	}
+
+	for n.FirstChild != nil {
+		n.RemoveChild(n.FirstChild)
+	}
+	n.Data, n.DataAtom = "en-media", 0
+	n.Attr = []html.Attribute{{Key: "type", Val: mimeType}, {Key: "hash", Val: hash}}
+}
+
+var reTodoText = regexp.MustCompile(`^\[([ xX])\] `)
+
+// todo turns a checkbox written as text at the beginning of a paragraph into en-todo
+func todo(n *html.Node) {
+	c := n.FirstChild
+	if c == nil || c.Type != html.TextNode {
+		return
+	}
+	m := reTodoText.FindStringSubmatch(c.Data)
+	if m == nil {
+		return
+	}
+	c.Data = c.Data[len(m[0]):]
+	n.InsertBefore(&html.Node{
+		Type: html.ElementNode,
+		Data: "en-todo",
+		Attr: []html.Attribute{{Key: "checked", Val: fmt.Sprint(m[1] != " ")}},
+	}, c)
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unwrap replaces the node with its children
+func unwrap(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		n.RemoveChild(c)
+		n.Parent.InsertBefore(c, n)
+	}
+	n.Parent.RemoveChild(n)
+}
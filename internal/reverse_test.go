@@ -0,0 +1,95 @@
+package internal_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+const reverseNote = `---
+date: 2020-12-01T09:00:00Z
+updated_at: 2020-12-02T09:00:00Z
+tags: [home, food]
+author: Jane Doe
+url: https://example.com/list
+---
+
+# Shopping \*list\*
+
+- [x] milk
+- [ ] bread
+
+![](image/cat.png) see [the receipt](file/receipt.txt) and [another note](Other.md)
+
+<script>alert(1)</script><span class="x" onclick="alert(1)">kept</span>
+`
+
+func TestReverse_Convert(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"image/cat.png":    "png",
+		"file/receipt.txt": "small",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := internal.NewReverse("").Convert([]byte(reverseNote), filepath.Join(dir, "Shopping.md"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != "Shopping *list*" {
+		t.Errorf("Convert() title = %s, want Shopping *list*", got.Title)
+	}
+	if got.Created != "20201201T090000Z" || got.Updated != "20201202T090000Z" {
+		t.Errorf("Convert() created = %s updated = %s", got.Created, got.Updated)
+	}
+	if !reflect.DeepEqual(got.Tags, []string{"home", "food"}) {
+		t.Errorf("Convert() tags = %v, want [home food]", got.Tags)
+	}
+	if got.Attributes.Author != "Jane Doe" || got.Attributes.SourceUrl != "https://example.com/list" {
+		t.Errorf("Convert() attributes = %+v", got.Attributes)
+	}
+
+	for _, want := range []string{
+		`<en-todo checked="true"></en-todo>milk`,
+		`<en-todo checked="false"></en-todo>bread`,
+		`<en-media type="image/png" hash="bff139fa05ac583f685a523ab3d110a0"></en-media>`,
+		`<en-media type="text/plain" hash="eb5c1399a871211c7e7ed732d15e3a8b"></en-media>`,
+		`another note`,
+		`<span>kept</span>`,
+	} {
+		if !strings.Contains(string(got.Content), want) {
+			t.Errorf("Convert() content = %s, want to contain %s", got.Content, want)
+		}
+	}
+	for _, unwanted := range []string{"<h1>", "Other.md", "script", "<input", "onclick"} {
+		if strings.Contains(string(got.Content), unwanted) {
+			t.Errorf("Convert() content = %s, want no %s", got.Content, unwanted)
+		}
+	}
+
+	var names []string
+	for _, r := range got.Resources {
+		names = append(names, r.Attributes.Filename)
+	}
+	if !reflect.DeepEqual(names, []string{"cat.png", "receipt.txt"}) {
+		t.Errorf("Convert() resources = %v, want [cat.png receipt.txt]", names)
+	}
+	if data := readResource(t, markdown.Resource{Open: got.Resources[1].Data.Open}); string(data) != "small" {
+		t.Errorf("Convert() resource data = %s, want small", data)
+	}
+}
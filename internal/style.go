@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+)
+
+// TextStyle defines how text formatting without a markdown equivalent is converted
+type TextStyle string
+
+const (
+	// TextHTML keeps underline, subscript, superscript and text color as inline HTML
+	TextHTML TextStyle = "html"
+	// TextMarkdown drops formatting that markdown can't express
+	TextMarkdown TextStyle = "markdown"
+)
+
+// ParseTextStyle returns a text style by name, empty name means HTML
+func ParseTextStyle(name string) (TextStyle, error) {
+	switch s := TextStyle(strings.ToLower(name)); s {
+	case "":
+		return TextHTML, nil
+	case TextHTML, TextMarkdown:
+		return s, nil
+	}
+
+	return "", fmt.Errorf("unknown text style %q, supported styles: html, markdown", name)
+}
+
+// TextFormatter converts inline styles of Evernote spans to formatting tags
+//
+// Bold, italic and strikethrough are always converted, underline, subscript, superscript
+// and text color are kept only with TextHTML style. Paragraphs made of a single span
+// with a large font become headings.
+type TextFormatter struct {
+	// Style is TextHTML if empty
+	Style TextStyle
+}
+
+// Minimal font sizes in pixels of headings from the second level,
+// the first level is kept for the note title
+var headingSizes = []float64{24, 20, 18}
+
+// ReplaceTag implements the TagReplacer interface
+func (r *TextFormatter) ReplaceTag(n *html.Node) {
+	if n.Type != html.ElementNode {
+		return
+	}
+	switch n.Data {
+	case "div", "p":
+		formatHeading(n)
+	case "span":
+		r.format(n)
+	}
+}
+
+func (r *TextFormatter) format(n *html.Node) {
+	style := markdown.ParseStyle(strings.ToLower(attr(n.Attr, "style")))
+	var tags []html.Node
+	if w := style["font-weight"]; w == "bold" || w == "bolder" || w == "700" || w == "800" || w == "900" {
+		tags = append(tags, html.Node{Data: "strong"})
+	}
+	if style["font-style"] == "italic" {
+		tags = append(tags, html.Node{Data: "i"})
+	}
+	decoration := style["text-decoration"] + " " + style["text-decoration-line"]
+	if strings.Contains(decoration, "line-through") {
+		tags = append(tags, html.Node{Data: "s"})
+	}
+	if r.Style != TextMarkdown {
+		if strings.Contains(decoration, "underline") {
+			tags = append(tags, html.Node{Data: "u"})
+		}
+		switch style["vertical-align"] {
+		case "sub":
+			tags = append(tags, html.Node{Data: "sub"})
+		case "super":
+			tags = append(tags, html.Node{Data: "sup"})
+		}
+		if c := style["color"]; c != "" && !isDefaultColor(c) {
+			tags = append(tags, html.Node{Data: "font", Attr: []html.Attribute{{Key: "color", Val: c}}})
+		}
+	}
+	if len(tags) == 0 {
+		return
+	}
+
+	if _, highlighted := markdown.HighlightColor(n); !highlighted {
+		// The span itself becomes the outer tag, highlighted spans are kept for the highlight rule
+		n.Data, n.Attr = tags[0].Data, tags[0].Attr
+		n.DataAtom = atom.Lookup([]byte(n.Data))
+		tags = tags[1:]
+	}
+	var children []*html.Node
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		n.RemoveChild(c)
+		children = append(children, c)
+	}
+	inner := n
+	for i := range tags {
+		tag := &html.Node{Type: html.ElementNode, Data: tags[i].Data, DataAtom: atom.Lookup([]byte(tags[i].Data)), Attr: tags[i].Attr}
+		inner.AppendChild(tag)
+		inner = tag
+	}
+	for _, c := range children {
+		inner.AppendChild(c)
+	}
+}
+
+// formatHeading turns a paragraph with a single span in a large font into a heading
+func formatHeading(n *html.Node) {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && (p.Data == "li" || p.Data == "td" || p.Data == "th") {
+			return // Headings don't fit in lists and tables
+		}
+	}
+
+	var span *html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == html.TextNode && strings.TrimSpace(c.Data) == "":
+		case c.Type == html.ElementNode && c.Data == "br":
+		case c.Type == html.ElementNode && c.Data == "span" && span == nil:
+			span = c
+		default:
+			return
+		}
+	}
+	if span == nil || strings.TrimSpace(textContent(span)) == "" {
+		return
+	}
+	style := markdown.ParseStyle(strings.ToLower(attr(span.Attr, "style")))
+	level := headingLevel(fontSize(style["font-size"]))
+	if level == 0 {
+		return
+	}
+
+	n.Data = "h" + strconv.Itoa(level)
+	n.DataAtom, n.Attr = atom.Lookup([]byte(n.Data)), nil
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && c.Data == "br" {
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+	// Headings are bold already
+	delete(style, "font-size")
+	delete(style, "font-weight")
+	for i, a := range span.Attr {
+		if a.Key == "style" {
+			span.Attr[i].Val = style.String()
+		}
+	}
+}
+
+func headingLevel(px float64) int {
+	for i, size := range headingSizes {
+		if px >= size {
+			return i + 2
+		}
+	}
+
+	return 0
+}
+
+// fontSize in pixels of a CSS font-size value, 0 if unknown
+func fontSize(value string) float64 {
+	switch value {
+	case "large":
+		return 18
+	case "x-large":
+		return 24
+	case "xx-large":
+		return 32
+	case "xxx-large":
+		return 48
+	}
+	units := []struct {
+		suffix string
+		px     float64
+	}{{"px", 1}, {"pt", 4.0 / 3}, {"rem", 16}, {"em", 16}, {"%", 0.16}}
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(value, u.suffix); ok {
+			size, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+			if err != nil {
+				return 0
+			}
+			return size * u.px
+		}
+	}
+
+	return 0
+}
+
+// isDefaultColor reports colors Evernote sets on plain text: black,
+// the default dark gray rgb(51, 51, 51) and other grays close to black
+func isDefaultColor(c string) bool {
+	switch c = markdown.NormalizeColor(c); c {
+	case "black", "inherit", "initial", "currentcolor":
+		return true
+	}
+	var r, g, b uint8
+	if n, _ := fmt.Sscanf(c, "#%02x%02x%02x", &r, &g, &b); n != 3 || len(c) != 7 {
+		return false
+	}
+
+	return max(r, g, b) <= 0x33 && max(r, g, b)-min(r, g, b) <= 0x10
+}
@@ -0,0 +1,81 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+func TestConvert_TextStyle(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		style   internal.TextStyle
+		want    string
+	}{
+		{
+			name:    "strikethrough",
+			content: `<div><span style="text-decoration: line-through;">old</span> new</div>`,
+			want:    "~~old~~ new\n",
+		},
+		{
+			name:    "combined styles",
+			content: `<div><span style="font-weight: bold; font-style: italic; text-decoration: underline;">all</span></div>`,
+			want:    "**_<u>all</u>_**\n",
+		},
+		{
+			name:    "sub and superscript",
+			content: `<div>H<span style="vertical-align: sub;">2</span>O, x<span style="vertical-align: super;">2</span></div>`,
+			want:    "H<sub>2</sub>O, x<sup>2</sup>\n",
+		},
+		{
+			name:    "color",
+			content: `<div><span style="color: rgb(227, 0, 0);">red</span> <span style="color: rgb(0, 0, 0);">black</span></div>`,
+			want:    `<span style="color: rgb(227, 0, 0)">red</span> black` + "\n",
+		},
+		{
+			name: "default text color",
+			content: `<div><span style="color: rgb(51, 51, 51); font-family: &quot;Source Sans Pro&quot;;">gray</span> ` +
+				`<span style="color:#333333;">hex</span> <span style="color: #1A1A1A;">dark</span> <span style="color: rgb(51, 0, 0);">maroon</span></div>`,
+			want: `gray hex dark <span style="color: rgb(51, 0, 0)">maroon</span>` + "\n",
+		},
+		{
+			name:    "pure markdown",
+			content: `<div><span style="color: red; text-decoration: underline line-through;">gone</span> H<sub>2</sub>O</div>`,
+			style:   internal.TextMarkdown,
+			want:    "~~gone~~ H2O\n",
+		},
+		{
+			name:    "font size heading",
+			content: `<div><span style="font-size: 24px; font-weight: bold;">Big</span></div><div><span style="font-size: 15pt;">Medium</span><br/></div><div><span style="font-size: 12px;">Small</span></div>`,
+			want:    "## Big\n\n### Medium\n\nSmall\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := internal.NewConverter("", false, false, false)
+			if tt.style != "" {
+				c.TextStyle = tt.style
+			}
+			got, err := c.ConvertTo(&enex.Note{Title: "Styles", Content: []byte(tt.content)}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "# Styles\n\n" + tt.want; string(got.Content) != want {
+				t.Errorf("ConvertTo() = %q, want %q", got.Content, want)
+			}
+		})
+	}
+}
+
+func TestParseTextStyle(t *testing.T) {
+	for name, want := range map[string]internal.TextStyle{"": internal.TextHTML, "HTML": internal.TextHTML, "markdown": internal.TextMarkdown} {
+		if got, err := internal.ParseTextStyle(name); err != nil || got != want {
+			t.Errorf("ParseTextStyle(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := internal.ParseTextStyle("rtf"); err == nil {
+		t.Error("ParseTextStyle() expected an error for unknown style")
+	}
+}
@@ -15,24 +15,37 @@ const tagToken = "{{tag}}"
 
 var spaces = regexp.MustCompile(`\s+`)
 
-func (c *Converter) prependTags(note *enex.Note, md *markdown.Note) {
-	if c.err != nil {
-		return
-	}
+func (c *Converter) prependTags(note *enex.Note, md *markdown.Note) error {
 	md.Content = append([]byte("\n\n"), md.Content...)
 	md.Content = append([]byte(c.tagList(note, c.TagTemplate, " ", c.TagTemplate != DefaultTagTemplate)), md.Content...)
+
+	return nil
 }
 
 func (c *Converter) tagList(note *enex.Note, tagTemplate string, joinString string, spacesToUnderscores bool) string {
 	var tt []string
+	for _, t := range c.tags(note, spacesToUnderscores) {
+		tt = append(tt, strings.Replace(tagTemplate, tagToken, t, 1))
+	}
+	return strings.Join(tt, joinString)
+}
+
+func (c *Converter) tags(note *enex.Note, spacesToUnderscores bool) []string {
+	var tt []string
 
 	for _, t := range note.Tags {
+		switch {
+		case c.Profile == ObsidianProfile:
+			t = obsidianTag(t)
+		case c.Profile == LogseqProfile:
+			// Commas separate tags in Logseq properties
+			t = strings.ReplaceAll(t, ",", "")
 		// Default tag template allows spaces in tags, but for custom templates
 		// we replace all spaces with underscores to prevent word splitting
-		if spacesToUnderscores {
+		case spacesToUnderscores:
 			t = spaces.ReplaceAllString(t, "_")
 		}
-		tt = append(tt, strings.Replace(tagTemplate, tagToken, t, 1))
+		tt = append(tt, t)
 	}
-	return strings.Join(tt, joinString)
+	return tt
 }
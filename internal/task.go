@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+)
+
+// Tasks replaces task group placeholders of Evernote v10 with checklists
+//
+// A placeholder is an empty element with a style like "--en-task-group:true; --en-id:<group>"
+// or an <en-task-group> element with an "id" attribute
+type Tasks struct {
+	groups map[string][]enex.Task
+
+	// ObsidianTasks adds dates and priorities in the format of Obsidian Tasks plugin
+	ObsidianTasks bool
+}
+
+// NewReplacerTasks creates a Tasks TagReplacer for the tasks of the note
+func NewReplacerTasks(tasks []enex.Task) *Tasks {
+	groups := map[string][]enex.Task{}
+	for _, t := range tasks {
+		groups[t.GroupID] = append(groups[t.GroupID], t)
+	}
+	for _, tt := range groups {
+		sort.SliceStable(tt, func(i, j int) bool { return tt[i].SortWeight < tt[j].SortWeight })
+	}
+
+	return &Tasks{groups: groups}
+}
+
+// ReplaceTag implements the TagReplacer interface
+func (r *Tasks) ReplaceTag(n *html.Node) {
+	id, ok := taskGroupID(n)
+	if !ok {
+		return
+	}
+
+	var list strings.Builder
+	list.WriteString("<ul>")
+	for _, t := range r.groups[id] {
+		_, _ = fmt.Fprintf(&list, `<li><en-todo checked="%t"></en-todo>%s</li>`, t.Completed(), html.EscapeString(r.taskText(t)))
+	}
+	list.WriteString("</ul>")
+
+	// Keep the node in place to continue walking the document
+	n.Data, n.DataAtom, n.Attr = "div", atom.Div, nil
+	n.AppendChild(parseOne(list.String(), n))
+}
+
+func taskGroupID(n *html.Node) (string, bool) {
+	if n.Type != html.ElementNode {
+		return "", false
+	}
+	if n.Data == "en-task-group" {
+		return attr(n.Attr, "id"), true
+	}
+
+	style := markdown.ParseStyle(attr(n.Attr, "style"))
+	if style["--en-task-group"] != "true" {
+		return "", false
+	}
+
+	return style["--en-id"], true
+}
+
+var obsidianPriority = map[string]string{
+	"high":   "⏫",
+	"medium": "🔼",
+	"low":    "🔽",
+}
+
+// taskText is a title of the task followed by its due date and priority
+func (r *Tasks) taskText(t enex.Task) string {
+	text := strings.TrimSpace(t.Title)
+	loc := taskLocation(t)
+	due, _ := enex.ParseDate(t.DueDate)
+	due = due.In(loc)
+	priority := strings.ToLower(strings.TrimSpace(t.Priority))
+	if priority == "" && t.Flag {
+		priority = "high"
+	}
+
+	if r.ObsidianTasks {
+		if p, ok := obsidianPriority[priority]; ok {
+			text += " " + p
+		}
+		if !due.IsZero() {
+			text += " 📅 " + due.Format("2006-01-02")
+		}
+		if done, err := enex.ParseDate(t.StatusUpdated); err == nil && t.Completed() {
+			text += " ✅ " + done.In(loc).Format("2006-01-02")
+		}
+		return text
+	}
+
+	var details []string
+	if !due.IsZero() {
+		layout := "2006-01-02"
+		if t.DueDateUIOption == "date_time" {
+			layout = "2006-01-02 15:04"
+		}
+		details = append(details, "due "+due.Format(layout))
+	}
+	if priority != "" {
+		details = append(details, priority+" priority")
+	}
+	if t.Assignee != "" {
+		details = append(details, "assigned to "+t.Assignee)
+	}
+	if len(details) > 0 {
+		text += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return text
+}
+
+// taskLocation is the time zone dates of the task are shown in, UTC if it is unknown
+func taskLocation(t enex.Task) *time.Location {
+	loc, err := time.LoadLocation(t.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// addTaskGroups appends placeholders for tasks with groups missing in the content,
+// so that no task is lost
+func (c *Converter) addTaskGroups(note *enex.Note, _ *markdown.Note) error {
+	seen := map[string]bool{}
+	for _, t := range note.Tasks {
+		if seen[t.GroupID] || (t.GroupID != "" && bytes.Contains(note.Content, []byte(t.GroupID))) {
+			continue
+		}
+		seen[t.GroupID] = true
+		note.Content = append(note.Content, fmt.Sprintf(`<en-task-group id="%s"></en-task-group>`, html.EscapeString(t.GroupID))...)
+	}
+
+	return nil
+}
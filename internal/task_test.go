@@ -0,0 +1,64 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+var testTasks = []enex.Task{
+	{Title: "Book a van", Status: "open", Flag: true, SortWeight: "B", GroupID: "g1", DueDate: "20240501T100000Z", Assignee: "Jane"},
+	{Title: "Pack <books>", Status: "completed", SortWeight: "A", GroupID: "g1", StatusUpdated: "20240403T090000Z"},
+	{Title: "Say goodbye", Status: "open", Priority: "low", GroupID: "g2"},
+}
+
+func TestConvert_Tasks(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		obsidianTasks bool
+		tasks         []enex.Task
+		want          string
+	}{
+		{
+			name:    "placeholder",
+			content: `<div>Before</div><div style="--en-task-group:true; --en-id:g1;"></div><div>After</div>`,
+			want: "# Moving\n\nBefore\n\n- [x] Pack <books>\n" +
+				"- [ ] Book a van (due 2024-05-01, high priority, assigned to Jane)\n\n" +
+				"After\n\n" +
+				"- [ ] Say goodbye (low priority)\n",
+		},
+		{
+			name:          "obsidian tasks",
+			content:       `<div style="--en-task-group:true; --en-id:g1;"></div><div style="--en-task-group:true; --en-id:g2;"></div>`,
+			obsidianTasks: true,
+			want: "# Moving\n\n- [x] Pack <books> ✅ 2024-04-03\n" +
+				"- [ ] Book a van ⏫ 📅 2024-05-01\n\n" +
+				"- [ ] Say goodbye 🔽\n",
+		},
+		{
+			name:    "due date in the time zone of the task",
+			content: `<en-task-group id="g1"></en-task-group>`,
+			tasks: []enex.Task{{Title: "Call", Status: "open", GroupID: "g1", DueDate: "20240501T230000Z",
+				DueDateUIOption: "date_time", TimeZone: "Europe/Berlin"}},
+			want: "# Moving\n\n- [ ] Call (due 2024-05-02 01:00)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := internal.NewConverter("", false, false, false)
+			c.ObsidianTasks = tt.obsidianTasks
+			if tt.tasks == nil {
+				tt.tasks = testTasks
+			}
+			got, err := c.ConvertTo(&enex.Note{Title: "Moving", Created: "20240401T090000Z", Updated: "20240401T090000Z", Content: []byte(tt.content), Tasks: tt.tasks}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got.Content) != tt.want {
+				t.Errorf("ConvertTo() = %q, want %q", got.Content, tt.want)
+			}
+		})
+	}
+}
@@ -9,11 +9,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
@@ -23,11 +25,14 @@ import (
 	"github.com/integrii/flaggy"
 
 	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
 	"github.com/wormi4ok/evernote2md/internal"
 )
 
 var version = "dev"
 
+const passphraseEnv = "EVERNOTE2MD_PASSPHRASE"
+
 func init() {
 	flaggy.SetName("evernote2md")
 	flaggy.SetDescription(" Convert Evernote notes exported in *.enex format to markdown files")
@@ -39,19 +44,61 @@ func init() {
 }
 
 func main() {
-	var input, outputOverride string
+	var input, outputOverride, profile, frontMatterFormat, frontMatterTemplate, passphrase, passphraseFile, notebookMapping, attachmentsDir, remindersReport, planFile, report, textStyle, highlightStyle, highlightColors, codeLanguage string
 	var outputDir = filepath.FromSlash("./notes")
 	var tagTemplate = internal.DefaultTagTemplate
-	var folders, noHighlights, escapeSpecialChars, resetTimestamps, addFrontMatter, debug bool
+	var jobs = runtime.NumCPU()
+	var filter filterOptions
+	var reverse, dryRun, strict, keepGoing, folders, notebooks, incremental, prune, readableAttachmentNames, strictAttachments, obsidianTasks, verbatimCode, noHighlights, noNoteLinks, escapeSpecialChars, resetTimestamps, addFrontMatter, debug bool
 
 	flaggy.AddPositionalValue(&input, "input", 1, true, "Evernote export file, directory or a glob pattern")
 	flaggy.AddPositionalValue(&outputDir, "output", 2, false, "Output directory")
 
+	flaggy.Bool(&reverse, "", "reverse", "Convert a directory with markdown files back to an Evernote export file given as output")
+
 	flaggy.String(&tagTemplate, "t", "tagTemplate", "Define how Evernote tags are formatted")
 	flaggy.String(&outputOverride, "o", "outputDir", "Override the directory where markdown files will be created")
+	flaggy.String(&profile, "", "profile", "Adjust the output to a markdown application: default, obsidian, logseq")
+	flaggy.String(&textStyle, "", "textStyle", "Keep underline, sub/superscript and text color as inline HTML or drop them for pure markdown: html, markdown")
+	flaggy.String(&highlightStyle, "", "highlightStyle", "Convert Evernote highlights to: span, mark, equals for ==text==")
+	flaggy.String(&highlightColors, "", "highlightColors", "A file mapping highlight colors to CSS classes or labels, e.g. 'yellow = important'")
+	flaggy.String(&codeLanguage, "", "codeLanguage", "Language of code blocks without a language set in Evernote or recognized from the code")
+	flaggy.String(&frontMatterFormat, "", "frontMatterFormat", "Prepend FrontMatter in a given format to markdown files: yaml, toml, json")
+	flaggy.String(&frontMatterTemplate, "", "frontMatterTemplate", "Prepend FrontMatter rendered from a Go template file to markdown files")
+	flaggy.String(&passphrase, "", "passphrase", "Passphrase to decrypt encrypted note sections (or set "+passphraseEnv+" variable)")
+	flaggy.String(&passphraseFile, "", "passphraseFile", "Read the passphrase to decrypt encrypted note sections from a file")
+
+	flaggy.String(&notebookMapping, "", "notebookMapping", "A file mapping export file names to notebook directories, e.g. 'Recipes.enex = Home/Recipes'")
+
+	flaggy.String(&attachmentsDir, "", "attachmentsDir", "Save attachments of all notes once in a shared directory inside the output directory")
+	flaggy.String(&remindersReport, "", "remindersReport", "Save a CSV list of notes with Evernote reminders to a file")
+	flaggy.String(&report, "", "report", "Save the status of every note to a JSON file, or CSV if the name ends with .csv")
+	flaggy.Int(&jobs, "j", "jobs", "Number of notes to convert in parallel")
+	flaggy.Bool(&dryRun, "", "dry-run", "Convert notes without writing anything and print the planned output")
+	flaggy.String(&planFile, "", "planFile", "Save the dry-run plan as JSON to a file, - for stdout, implies --dry-run")
+	flaggy.Bool(&strict, "", "strict", "Stop at the first note that fails to convert or save")
+	flaggy.Bool(&keepGoing, "", "keep-going", "Skip input files that can't be read instead of stopping")
+
+	flaggy.StringSlice(&filter.tags, "", "tag", "Convert only notes with any of the tags, can be repeated or comma-separated")
+	flaggy.StringSlice(&filter.excludeTags, "", "excludeTag", "Skip notes with any of the tags, can be repeated or comma-separated")
+	flaggy.String(&filter.createdAfter, "", "createdAfter", "Convert only notes created on or after a date, e.g. 2020-01-31")
+	flaggy.String(&filter.createdBefore, "", "createdBefore", "Convert only notes created before a date")
+	flaggy.String(&filter.updatedAfter, "", "updatedAfter", "Convert only notes updated on or after a date")
+	flaggy.String(&filter.updatedBefore, "", "updatedBefore", "Convert only notes updated before a date")
+	flaggy.String(&filter.title, "", "title", "Convert only notes with titles matching a regular expression")
+	flaggy.String(&filter.source, "", "source", "Convert only notes from export files matching a glob pattern, e.g. 'Work*.enex'")
+	flaggy.Bool(&filter.hasAttachments, "", "hasAttachments", "Convert only notes with attachments")
 
 	flaggy.Bool(&folders, "", "folders", "Put every note in a separate folder")
+	flaggy.Bool(&notebooks, "", "notebooks", "Put notes from every export file in a notebook folder named after the file")
+	flaggy.Bool(&incremental, "", "incremental", "Skip notes that didn't change since the previous run")
+	flaggy.Bool(&prune, "", "prune", "Remove notes missing in the export since the previous run, requires --incremental")
+	flaggy.Bool(&readableAttachmentNames, "", "readableAttachmentNames", "Name attachments in the shared directory after original files instead of MD5 hash only")
+	flaggy.Bool(&strictAttachments, "", "strictAttachments", "Fail notes with attachments not matching their MD5 hash instead of saving corrupted files")
+	flaggy.Bool(&obsidianTasks, "", "obsidianTasks", "Add due dates and priorities to Evernote tasks in the format of Obsidian Tasks plugin")
+	flaggy.Bool(&verbatimCode, "", "verbatimCode", "Keep code blocks exactly as they are, including trailing spaces and blank lines")
 	flaggy.Bool(&noHighlights, "", "noHighlights", "Disable converting Evernote highlights to inline HTML tags")
+	flaggy.Bool(&noNoteLinks, "", "noNoteLinks", "Disable converting Evernote note links to relative links between markdown files")
 	flaggy.Bool(&escapeSpecialChars, "", "escape-special-chars", "Escape special characters to ensure correct rendering of the converted files")
 	flaggy.Bool(&resetTimestamps, "", "resetTimestamps", "Create files ignoring timestamps in the note attributes")
 	flaggy.Bool(&addFrontMatter, "", "addFrontMatter", "Prepend FrontMatter to markdown files")
@@ -63,14 +110,102 @@ func main() {
 		outputDir = outputOverride
 	}
 
+	if reverse {
+		output := outputDir
+		if output == filepath.FromSlash("./notes") {
+			// Name the export after the directory by default
+			abs, err := filepath.Abs(input)
+			failWhen(err)
+			output = filepath.Base(abs) + ".enex"
+		}
+		setLogLevel(debug)
+		os.Exit(reverseRun(input, output, newSpinner(debug), internal.NewReverse(tagTemplate)).exitCode())
+	}
+
 	files, err := matchInput(input)
-	failWhen(err)
+	failUsage(err)
+	if jobs < 1 {
+		failUsage(fmt.Errorf("--jobs should be at least 1, got %d", jobs))
+	}
 	output := newNoteFilesDir(outputDir, folders, !resetTimestamps)
+	if notebooks || notebookMapping != "" {
+		var mapping map[string]string
+		if notebookMapping != "" {
+			mapping, err = readNotebookMapping(notebookMapping)
+			failWhen(err)
+		}
+		output.EnableNotebooks(mapping)
+	}
+	if prune && !incremental {
+		failUsage(errors.New("--prune requires --incremental"))
+	}
 	converter, err := internal.NewConverter(tagTemplate, addFrontMatter, !noHighlights, escapeSpecialChars)
+	failUsage(err)
+	p, err := internal.ParseProfile(profile)
+	failUsage(err)
+	converter.UseProfile(p)
+	converter.FrontMatterFormat, err = internal.ParseFrontMatterFormat(frontMatterFormat)
+	failUsage(err)
+	if frontMatterFormat != "" {
+		converter.EnableFrontMatter = true
+	}
+	if frontMatterTemplate != "" {
+		b, err := os.ReadFile(frontMatterTemplate)
+		failWhen(err)
+		failUsage(converter.SetFrontMatterTemplate(string(b)))
+		converter.EnableFrontMatter = true
+	}
+	if p == internal.LogseqProfile {
+		if folders || notebooks || notebookMapping != "" || attachmentsDir != "" {
+			failUsage(errors.New("logseq profile defines the graph layout, it can't be used with --folders, --notebooks, --notebookMapping or --attachmentsDir"))
+		}
+		output.EnableLogseq()
+	}
+	converter.TextStyle, err = internal.ParseTextStyle(textStyle)
+	failUsage(err)
+	converter.HighlightStyle, err = markdown.ParseHighlightStyle(highlightStyle)
+	failUsage(err)
+	if highlightColors != "" {
+		converter.HighlightColors, err = readHighlightColors(highlightColors)
+		failWhen(err)
+	}
+	converter.CodeLanguage, converter.VerbatimCode = codeLanguage, verbatimCode
+	converter.ObsidianTasks = obsidianTasks
+	converter.ResourceCheck = internal.NewResourceCheck()
+	converter.StrictResources = strictAttachments
+	converter.Passphrase, err = readPassphrase(passphrase, passphraseFile)
 	failWhen(err)
+	if attachmentsDir != "" {
+		output.EnableAttachmentsDir(attachmentsDir)
+		converter.AttachmentsDir = filepath.ToSlash(filepath.Clean(attachmentsDir))
+		converter.ReadableAttachmentNames = readableAttachmentNames
+	}
+	if !noNoteLinks {
+		converter.NoteLinks = internal.NewNoteIndex()
+	}
+	if incremental {
+		failWhen(output.EnableIncremental(prune, optionsDigest(converter, output)))
+	}
+
+	if dryRun || planFile != "" {
+		output.EnableDryRun(newConversionPlan(planFile))
+	}
+
+	opts := runOptions{jobs: jobs, strict: strict, keepGoing: keepGoing}
+	opts.filter, err = newNoteFilter(filter)
+	failUsage(err)
+	if remindersReport != "" {
+		opts.reminders = newReminderReport(remindersReport)
+	}
+	if report != "" {
+		opts.report = newConversionReport(report)
+		if !filepath.IsAbs(input) {
+			opts.report.relativeTo, _ = os.Getwd()
+		}
+	}
 
 	setLogLevel(debug)
-	run(files, output, newSpinner(debug), converter)
+	os.Exit(run(files, output, newSpinner(debug), converter, opts).exitCode())
 }
 
 func newSpinner(disabled bool) *spinner.Spinner {
@@ -81,48 +216,214 @@ func newSpinner(disabled bool) *spinner.Spinner {
 	return sp
 }
 
-func run(files []string, output *noteFilesDir, sp *spinner.Spinner, c *internal.Converter) {
-	log.Printf("[DEBUG] Creating a directory: %s", output.Path())
-	err := os.MkdirAll(output.Path(), os.ModePerm)
-	failWhen(err)
+// runOptions control the conversion process
+type runOptions struct {
+	// number of notes converted concurrently
+	jobs int
+	// a list of notes with reminders, saved at the end of the run if set
+	reminders *reminderReport
+	// notes to convert, all notes if nil
+	filter *noteFilter
+	// the status of every note, saved at the end of the run if set
+	report *conversionReport
+	// strict stops the run at the first note that fails to convert or save
+	strict bool
+	// keepGoing skips input files that can't be read instead of stopping the run, files that can't be decoded are always skipped
+	keepGoing bool
+}
+
+// Exit codes of the program
+const (
+	exitSuccess = 0
+	// exitFailure means that the run stopped early or none of the notes were converted
+	exitFailure = 1
+	// exitUsage means invalid flags or arguments
+	exitUsage = 2
+	// exitPartial means that some notes or input files failed
+	exitPartial = 3
+)
+
+// runStats count the results of a run
+type runStats struct {
+	converted, unchanged, excluded, failed int
+	// input files that couldn't be read or decoded
+	failedFiles int
+	// aborted is set when the run stopped at the first error
+	aborted bool
+}
 
-	cnt := 0
+func (s runStats) exitCode() int {
+	switch {
+	case s.aborted:
+		return exitFailure
+	case s.failed == 0 && s.failedFiles == 0:
+		return exitSuccess
+	case s.converted == 0 && s.unchanged == 0:
+		return exitFailure
+	}
+
+	return exitPartial
+}
+
+func run(files []string, output *noteFilesDir, sp *spinner.Spinner, c *internal.Converter, opts runOptions) runStats {
+	if output.plan == nil {
+		log.Printf("[DEBUG] Creating a directory: %s", output.Path())
+		failWhen(os.MkdirAll(output.Path(), os.ModePerm))
+	}
+
+	var stats runStats
 	start := time.Now()
 	sp.Start()
 
+	if c.NoteLinks != nil {
+		indexNotes(files, output.planner(), opts.filter, c.NoteLinks)
+	}
+
+	jobs := make(chan *noteJob)
+	slots := make(chan struct{}, 2*opts.jobs)
+	stop := make(chan struct{})
+	go decodeNotes(files, output, opts.filter, c.NoteLinks, jobs, slots, stop)
+
+	abort := func() {
+		stats.aborted = true
+		close(stop)
+		// Notes after the failure were not seen, they must not be pruned
+		output.markIncomplete()
+	}
+
+	inOrder(convertNotes(c, opts.jobs, jobs), slots, func(j *noteJob) {
+		defer func() {
+			if err := j.note.Close(); err != nil {
+				log.Printf("[WARN] Failed to remove temporary files of %q: %s", j.note.Title, err)
+			}
+		}()
+		if stats.aborted {
+			// Drain the notes converted before the run stopped
+			return
+		}
+		if output.plan != nil {
+			var media []string
+			if j.md != nil {
+				media = output.mediaPaths(j.path, j.md)
+			}
+			output.plan.add(j, media)
+		}
+		switch {
+		case j.unreadable:
+			progressError(j.err, j.file, "Failed to read file")
+			stats.failedFiles++
+			output.markIncomplete()
+		case j.broken:
+			progressError(j.err, j.file, "Failed to decode file")
+			stats.failedFiles++
+			output.markIncomplete()
+		case j.excluded:
+			// Keep manifest entries of the skipped notes, so that they are not pruned
+			output.manifest.skip(j.entry)
+			stats.excluded++
+		case j.unchanged:
+			output.manifest.keep(j.entry)
+			stats.unchanged++
+		case progressError(j.err, j.note.Title, "Failed to convert note"):
+			output.manifest.skip(j.entry)
+			stats.failed++
+		default:
+			if err := output.save(j.path, j.md); progressError(err, j.note.Title, "Failed to save note") {
+				j.err = fmt.Errorf("save note: %w", err)
+				output.manifest.skip(j.entry)
+				stats.failed++
+				break
+			}
+			output.record(j.entry, j.md)
+			stats.converted++
+		}
+		if opts.report != nil {
+			opts.report.add(j)
+		}
+		if opts.reminders != nil && !j.excluded && !j.broken {
+			opts.reminders.add(&j.note, j.path)
+		}
+		if (j.unreadable && !opts.keepGoing) || (j.err != nil && !j.broken && opts.strict) {
+			abort()
+		}
+	})
+	failWhen(output.Close())
+	if opts.reminders != nil && output.plan == nil {
+		failWhen(opts.reminders.save())
+	}
+	if opts.report != nil {
+		failWhen(opts.report.save())
+	}
+
+	sp.FinalMSG = fmt.Sprintf("Done!\nConverted %d notes in %s\n", stats.converted, durafmt.ParseShort(time.Since(start)))
+	if output.plan != nil {
+		sp.FinalMSG = fmt.Sprintf("Done!\nPlanned %d notes in %s, nothing was written\n", stats.converted, durafmt.ParseShort(time.Since(start)))
+	}
+	if stats.aborted {
+		sp.FinalMSG = fmt.Sprintf("Stopped after the first error!\nConverted %d notes in %s\n", stats.converted, durafmt.ParseShort(time.Since(start)))
+	}
+	if stats.unchanged > 0 {
+		sp.FinalMSG += fmt.Sprintf("Skipped %d unchanged notes\n", stats.unchanged)
+	}
+	if stats.excluded > 0 {
+		sp.FinalMSG += fmt.Sprintf("Skipped %d notes not matching the filters\n", stats.excluded)
+	}
+	if stats.failed > 0 || stats.failedFiles > 0 {
+		sp.FinalMSG += fmt.Sprintf("Failed %d notes and %d input files\n", stats.failed, stats.failedFiles)
+	}
+	var corrupted []string
+	if c.ResourceCheck != nil {
+		corrupted = c.ResourceCheck.Mismatches()
+	}
+	if len(corrupted) > 0 {
+		sp.FinalMSG += fmt.Sprintf("Found %d corrupted attachments\n", len(corrupted))
+	}
+	sp.Stop()
+
+	for _, mismatch := range corrupted {
+		log.Printf("[WARN] %s", mismatch)
+	}
+
+	if c.NoteLinks != nil {
+		for _, link := range c.NoteLinks.Unresolved() {
+			log.Printf("[WARN] Unresolved note link: %s", link)
+		}
+	}
+
+	if output.plan != nil {
+		failWhen(output.plan.report())
+	}
+
+	return stats
+}
+
+// indexNotes makes a first pass over the input files to learn where
+// every note is going to be saved, so that notes can link to each other
+func indexNotes(files []string, output *noteFilesDir, filter *noteFilter, index *internal.NoteIndex) {
 	for _, file := range files {
 		fd, err := os.Open(file)
-		failWhen(err)
-
-		log.Printf("[DEBUG] Decoding file: %s", file)
-		d, err := enex.NewStreamDecoder(fd)
-		if progressError(err, file, "Failed to decode file") {
+		if err != nil {
 			continue
 		}
 
-		for {
+		log.Printf("[DEBUG] Indexing file: %s", file)
+		output.OpenNotebook(file)
+		d, err := enex.NewStreamDecoder(fd)
+		if err == nil {
+			d.DiscardResourceData()
+		}
+		for err == nil {
 			note := enex.Note{}
-			if err := d.Next(&note); err != nil {
-				if err != io.EOF {
-					log.Printf("Failed to decode the next note: %s", err)
+			if err = d.Next(&note); err == nil {
+				if filter.match(file, &note) {
+					// Links to the skipped notes are reported as unresolved
+					index.Add(note.Title, notebookName(file, output.notebooks), filepath.ToSlash(output.notePath(note.Title)))
 				}
-				break
-			}
-			md, innerErr := c.Convert(&note)
-			if progressError(innerErr, note.Title, "Failed to convert note") {
-				continue
-			}
-			innerErr = output.SaveNote(note.Title, md)
-			if progressError(innerErr, note.Title, "Failed to save note") {
-				continue
+				index.Collect(&note)
 			}
-			cnt++
 		}
-		err = fd.Close()
-		failWhen(err)
+		_ = fd.Close()
 	}
-	sp.FinalMSG = fmt.Sprintf("Done!\nConverted %d notes in %s\n", cnt, durafmt.ParseShort(time.Since(start)))
-	sp.Stop()
 }
 
 func progressError(err error, name string, text string) bool {
@@ -172,6 +473,47 @@ func matchInput(input string) ([]string, error) {
 	return files, err
 }
 
+// readPassphrase from a flag, a file or an environment variable in this order
+func readPassphrase(passphrase, passphraseFile string) (string, error) {
+	if passphrase != "" {
+		return passphrase, nil
+	}
+
+	if passphraseFile != "" {
+		b, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("read passphrase file: %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	return os.Getenv(passphraseEnv), nil
+}
+
+// readHighlightColors from a file with "color = class or label" lines, colors are normalized
+// Lines starting with "# " are comments, "#" followed by a hex value is a color
+func readHighlightColors(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read highlight colors: %w", err)
+	}
+
+	colors := map[string]string{}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "#" || strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "##") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("read highlight colors: line %d: missing '='", i+1)
+		}
+		colors[markdown.NormalizeColor(key)] = strings.TrimSpace(value)
+	}
+
+	return colors, nil
+}
+
 func setLogLevel(debug bool) {
 	var logLevel logutils.LogLevel = "WARN"
 
@@ -191,3 +533,11 @@ func failWhen(err error) {
 		log.Fatal(fmt.Errorf("[ERROR] %w", err))
 	}
 }
+
+// failUsage exits when flags or arguments are invalid
+func failUsage(err error) {
+	if err != nil {
+		log.Print(fmt.Errorf("[ERROR] %w", err))
+		os.Exit(exitUsage)
+	}
+}
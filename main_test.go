@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -34,13 +35,55 @@ func Test_run(t *testing.T) {
 	files, _ := matchInput(input)
 	output := newNoteFilesDir(tmpDir, false, false)
 	converter, _ := internal.NewConverter("", true, false, true)
-	run(files, output, newSpinner(true), converter)
+	run(files, output, newSpinner(true), converter, runOptions{jobs: 2})
 
 	want := filepath.Join(output.Path(), "Test.md")
 	_, err = os.Stat(want)
 	if err != nil && os.IsNotExist(err) {
 		t.Error("Test.md was not created")
 	}
+	if _, err := os.Stat(filepath.Join(output.Path(), manifestName)); !os.IsNotExist(err) {
+		t.Error("manifest should be saved only in incremental mode")
+	}
+}
+
+// testRun converts exports written to a temporary directory the same way main does,
+// tests adjust the output, the converter and the options before the run
+type testRun struct {
+	t         *testing.T
+	dir       string
+	output    *noteFilesDir
+	converter *internal.Converter
+	opts      runOptions
+}
+
+// newTestRun converts notes to the "notes" directory inside dir with default options
+func newTestRun(t *testing.T, dir string) *testRun {
+	setLogLevel(false)
+	converter, _ := internal.NewConverter("", false, false, false)
+
+	return &testRun{
+		t:         t,
+		dir:       dir,
+		output:    newNoteFilesDir(filepath.Join(dir, "notes"), false, false),
+		converter: converter,
+		opts:      runOptions{jobs: 2},
+	}
+}
+
+// export writes an export file with the given name to the directory and returns its path
+func (r *testRun) export(name, content string) string {
+	r.t.Helper()
+	input := filepath.Join(r.dir, name)
+	if err := os.WriteFile(input, []byte(content), 0600); err != nil {
+		r.t.Fatal(err)
+	}
+
+	return input
+}
+
+func (r *testRun) run(files ...string) runStats {
+	return run(files, r.output, newSpinner(true), r.converter, r.opts)
 }
 
 func Test_matchInput_cwd(t *testing.T) {
@@ -148,3 +191,93 @@ func matchPath(got []string, want ...string) bool {
 
 	return true
 }
+
+func Test_runStats_exitCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats runStats
+		want  int
+	}{
+		{"success", runStats{converted: 2, unchanged: 1}, exitSuccess},
+		{"empty export", runStats{}, exitSuccess},
+		{"partial", runStats{converted: 2, failed: 1}, exitPartial},
+		{"skipped file", runStats{unchanged: 1, failedFiles: 1}, exitPartial},
+		{"total failure", runStats{failed: 2, excluded: 1}, exitFailure},
+		{"aborted", runStats{converted: 2, failed: 1, aborted: true}, exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stats.exitCode(); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+const strictFile = `<?xml version="1.0" encoding="UTF-8"?>
+<en-export>
+<note><title>Broken</title><content><![CDATA[<en-note><en-media type="text/plain" hash="00000000000000000000000000000000"/></en-note>]]></content>
+<resource><data encoding="base64">c21hbGw=</data><mime>text/plain</mime>
+<resource-attributes><source-url>en-cache://res/00000000000000000000000000000000</source-url></resource-attributes></resource></note>
+<note><title>Good</title><content><![CDATA[<en-note><div>text</div></en-note>]]></content></note>
+</en-export>
+`
+
+func Test_run_strict(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		r := newTestRun(t, tDir(t))
+		r.converter.StrictResources = true
+		r.opts.strict = strict
+		stats := r.run(r.export("export.enex", strictFile))
+
+		_, err := os.Stat(filepath.Join(r.output.Path(), "Good.md"))
+		if strict && (!os.IsNotExist(err) || stats.exitCode() != exitFailure) {
+			t.Errorf("run() with --strict = %+v, want to stop at the first note", stats)
+		}
+		if !strict && (err != nil || stats.exitCode() != exitPartial) {
+			t.Errorf("run() = %+v, want to convert the rest of notes", stats)
+		}
+	}
+}
+
+func Test_run_decodeFailure(t *testing.T) {
+	r := newTestRun(t, tDir(t))
+	stats := r.run(r.export("a.enex", "not an export"), r.export("b.enex", sampleFile))
+
+	if _, err := os.Stat(filepath.Join(r.output.Path(), "Test.md")); err != nil || stats.exitCode() != exitPartial || stats.failedFiles != 1 {
+		t.Errorf("run() = %+v, want to skip the file that can't be decoded: %v", stats, err)
+	}
+}
+
+func Test_run_keepGoing(t *testing.T) {
+	for _, keepGoing := range []bool{false, true} {
+		r := newTestRun(t, tDir(t))
+		r.opts.keepGoing = keepGoing
+		stats := r.run(filepath.Join(r.dir, "a.enex"), r.export("b.enex", sampleFile))
+
+		_, err := os.Stat(filepath.Join(r.output.Path(), "Test.md"))
+		if keepGoing && (err != nil || stats.exitCode() != exitPartial) {
+			t.Errorf("run() with --keep-going = %+v, want to skip the missing file", stats)
+		}
+		if !keepGoing && (!os.IsNotExist(err) || stats.exitCode() != exitFailure) {
+			t.Errorf("run() = %+v, want to stop at the missing file", stats)
+		}
+	}
+}
+
+func Test_readHighlightColors(t *testing.T) {
+	colorsFile := filepath.Join(t.TempDir(), "colors.txt")
+	content := "# Meaning of colors\n\n#FFEF9E = important\nrgb(255, 250, 165) = 🟡\nblue=question\n"
+	if err := os.WriteFile(colorsFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readHighlightColors(colorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"#ffef9e": "important", "#fffaa5": "🟡", "blue": "question"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readHighlightColors() = %v, want %v", got, want)
+	}
+}
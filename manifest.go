@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+// manifestName is a file in the output directory listing converted notes
+const manifestName = ".evernote2md.json"
+
+// manifestVersion changes when identities of notes change, notes are converted again then
+const manifestVersion = 2
+
+type (
+	// manifest keeps track of notes converted to the output directory
+	// to update only the changed notes on the next run
+	manifest struct {
+		Version int `json:"version"`
+		// Options is a digest of the options the notes were converted with
+		Options string          `json:"options"`
+		Notes   []manifestEntry `json:"notes"`
+
+		// notes from the previous run by identity, empty if they were converted with other options
+		previous map[string]manifestEntry
+		// identities of notes found in the current run and whether they were saved
+		present map[string]bool
+		// a counter of repeating identities
+		seen map[string]int
+	}
+
+	// manifestEntry describes one converted note
+	manifestEntry struct {
+		ID      string   `json:"id"`
+		Hash    string   `json:"hash"`
+		Source  string   `json:"source"`
+		Path    string   `json:"path"`
+		Media   []string `json:"media,omitempty"`
+		Updated string   `json:"updated"`
+		// Links is a checksum of the paths of the linked notes, the note changes with them
+		Links string `json:"links,omitempty"`
+	}
+)
+
+func newManifest() *manifest {
+	return &manifest{
+		Version:  manifestVersion,
+		previous: map[string]manifestEntry{},
+		present:  map[string]bool{},
+		seen:     map[string]int{},
+	}
+}
+
+// readManifest from the output directory, a missing manifest is not an error
+// Notes of a previous run with different options are treated as new
+func readManifest(dir, options string) (*manifest, error) {
+	m := newManifest()
+	m.Options = options
+	b, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	var previous manifest
+	if err := json.Unmarshal(b, &previous); err != nil {
+		return nil, fmt.Errorf("read manifest %s: %w", filepath.Join(dir, manifestName), err)
+	}
+	if previous.Version != manifestVersion || previous.Options != options {
+		log.Printf("[INFO] The previous run used other options or an older version, converting all notes")
+		return m, nil
+	}
+	for _, e := range previous.Notes {
+		m.previous[e.ID] = e
+	}
+
+	return m, nil
+}
+
+// entry describes a note from the export file saved at a given path
+// linking to notes at target paths
+func (m *manifest) entry(exportFile string, note *enex.Note, notePath string, targets []string) manifestEntry {
+	e := manifestEntry{
+		ID:      m.identity(exportFile, note),
+		Hash:    noteHash(note),
+		Source:  filepath.Base(exportFile),
+		Path:    filepath.ToSlash(notePath),
+		Updated: note.Updated,
+	}
+	if len(targets) > 0 {
+		e.Links = hashOf(targets...)
+	}
+
+	return e
+}
+
+// identity of the note, it has to be taken for every note in the export in order
+func (m *manifest) identity(exportFile string, note *enex.Note) string {
+	// Notes in the export don't have identifiers, so the export file, creation time and title
+	// are the most stable properties. Repeating identities are numbered in order.
+	id := hashOf(filepath.Base(exportFile), note.Created, note.Title)
+	if k := m.seen[id]; k > 0 {
+		m.seen[id]++
+		return hashOf(id, fmt.Sprint(k))
+	}
+	m.seen[id] = 1
+
+	return id
+}
+
+// unchanged reports whether the note was saved at the same path
+// with the same content and links and the file is still there
+func (m *manifest) unchanged(root string, e manifestEntry) bool {
+	prev, ok := m.previous[e.ID]
+	if !ok || prev.Hash != e.Hash || prev.Path != e.Path || prev.Links != e.Links {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(root, filepath.FromSlash(prev.Path)))
+
+	return err == nil
+}
+
+// add a saved note to the manifest
+func (m *manifest) add(e manifestEntry) {
+	m.Notes = append(m.Notes, e)
+	m.present[e.ID] = true
+}
+
+// keep the entry from the previous run for an unchanged note
+func (m *manifest) keep(e manifestEntry) {
+	m.add(m.previous[e.ID])
+}
+
+// skip marks a note as present in the export, but not saved in the current run
+func (m *manifest) skip(e manifestEntry) {
+	if _, ok := m.present[e.ID]; !ok {
+		m.present[e.ID] = false
+	}
+}
+
+// removed returns the notes from the previous run missing in the current one
+func (m *manifest) removed() []manifestEntry {
+	var removed []manifestEntry
+	for id, e := range m.previous {
+		if _, ok := m.present[id]; !ok {
+			removed = append(removed, e)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Path < removed[j].Path })
+
+	return removed
+}
+
+// keepPrevious copies entries of notes that were not saved in the current run,
+// because they failed to convert or were removed without pruning
+func (m *manifest) keepPrevious(pruned bool) {
+	var kept []manifestEntry
+	for id, e := range m.previous {
+		if saved, ok := m.present[id]; (ok && !saved) || (!ok && !pruned) {
+			kept = append(kept, e)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Path < kept[j].Path })
+	m.Notes = append(m.Notes, kept...)
+}
+
+// save the manifest to the output directory
+func (m *manifest) save(dir string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, manifestName), append(b, '\n'), 0644)
+}
+
+// optionsDigest is a checksum of the options that affect the converted notes
+func optionsDigest(c *internal.Converter, d *noteFilesDir) string {
+	options, _ := json.Marshal(map[string]any{
+		"tagTemplate":             c.TagTemplate,
+		"highlights":              c.EnableHighlights,
+		"escapeSpecialChars":      c.EscapeSpecialChars,
+		"frontMatter":             c.EnableFrontMatter,
+		"frontMatterFormat":       c.FrontMatterFormat,
+		"frontMatterTemplate":     c.FrontMatterTemplate,
+		"profile":                 c.Profile,
+		"decrypt":                 c.Passphrase != "", // The passphrase itself is not stored
+		"attachmentsDir":          c.AttachmentsDir,
+		"readableAttachmentNames": c.ReadableAttachmentNames,
+		"noteLinks":               c.NoteLinks != nil,
+		"strictAttachments":       c.StrictResources,
+		"textStyle":               c.TextStyle,
+		"highlightStyle":          c.HighlightStyle,
+		"highlightColors":         c.HighlightColors,
+		"codeLanguage":            c.CodeLanguage,
+		"verbatimCode":            c.VerbatimCode,
+		"obsidianTasks":           c.ObsidianTasks,
+		"folders":                 d.flagFolders,
+		"timestamps":              d.flagTimestamps,
+		"notebooks":               d.flagNotebooks,
+		"notebookMapping":         d.notebooks,
+		"logseq":                  d.flagLogseq,
+	})
+
+	return hashOf(string(options))
+}
+
+// noteHash is a checksum of everything in the note that affects the conversion result
+func noteHash(note *enex.Note) string {
+	h := sha256.New()
+	write := func(ss ...string) {
+		for _, s := range ss {
+			_, _ = io.WriteString(h, s)
+			_, _ = h.Write([]byte{0})
+		}
+	}
+
+	write(note.Title, note.Created, note.Updated, string(note.Content), strings.Join(note.Tags, ","))
+	attributes, _ := json.Marshal(note.Attributes)
+	tasks, _ := json.Marshal(note.Tasks)
+	write(string(attributes), string(tasks))
+	for _, r := range note.Resources {
+		write(r.ID, r.Mime, r.Attributes.Filename, r.Attributes.SourceUrl)
+		// Hash the decoded data as it may be kept in a temporary file
+		if data, err := r.Data.Open(); err == nil {
+			_, _ = io.Copy(h, data)
+			_ = data.Close()
+		}
+		_, _ = h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashOf(ss ...string) string {
+	h := sha256.Sum256([]byte(strings.Join(ss, "\x00")))
+	return hex.EncodeToString(h[:16])
+}
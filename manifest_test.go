@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+func Test_run_incremental(t *testing.T) {
+	tmpDir := tDir(t)
+	outputDir := filepath.Join(tmpDir, "notes")
+	converter, _ := internal.NewConverter("", false, false, false)
+
+	incrementalRun := func(content string, prune bool) {
+		r := newTestRun(t, tmpDir)
+		r.converter = converter
+		if err := r.output.EnableIncremental(prune, optionsDigest(r.converter, r.output)); err != nil {
+			t.Fatal(err)
+		}
+		r.run(r.export("export.enex", content))
+	}
+
+	notePath := filepath.Join(outputDir, "Test.md")
+	incrementalRun(sampleFile, false)
+	shouldExist(t, outputDir, manifestName)
+
+	// Local changes survive when the note is the same in the export
+	if err := os.WriteFile(notePath, []byte("local edits"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	incrementalRun(sampleFile, false)
+	if b, _ := os.ReadFile(notePath); string(b) != "local edits" {
+		t.Errorf("Unchanged note was overwritten with: %s", b)
+	}
+
+	// Notes are converted again with different options
+	converter.EnableFrontMatter = true
+	incrementalRun(sampleFile, false)
+	if b, _ := os.ReadFile(notePath); !strings.HasPrefix(string(b), "---\n") {
+		t.Errorf("Note was not converted with new options: %s", b)
+	}
+	if err := os.WriteFile(notePath, []byte("local edits"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	incrementalRun(sampleFile, false)
+	if b, _ := os.ReadFile(notePath); string(b) != "local edits" {
+		t.Errorf("Unchanged note was overwritten with: %s", b)
+	}
+
+	// Changed notes are updated
+	incrementalRun(strings.Replace(sampleFile, "<br />", "updated", 1), false)
+	if b, _ := os.ReadFile(notePath); !strings.Contains(string(b), "updated") {
+		t.Errorf("Changed note was not updated: %s", b)
+	}
+
+	// Missing notes are kept until pruned
+	renamedExport := strings.Replace(sampleFile, "<title>Test</title>", "<title>Other</title>", 1)
+	incrementalRun(renamedExport, false)
+	shouldExist(t, notePath)
+	incrementalRun(renamedExport, true)
+	if _, err := os.Stat(notePath); !os.IsNotExist(err) {
+		t.Errorf("Removed note was not pruned: %v", err)
+	}
+	shouldExist(t, outputDir, "Other.md")
+}
+
+const linkedFile = `<?xml version="1.0" encoding="UTF-8"?>
+<en-export>
+<note><title>Source</title><content><![CDATA[<en-note><a href="evernote:///view/1/s1/0b4f3d6e-9b43-4a8e-9e1a-3c2b1a0f5d7e/0b4f3d6e-9b43-4a8e-9e1a-3c2b1a0f5d7e/">Target</a></en-note>]]></content></note>
+<note><title>Target</title><content><![CDATA[<en-note><div>text</div></en-note>]]></content></note>
+</en-export>
+`
+
+func Test_run_incrementalLinks(t *testing.T) {
+	tmpDir := tDir(t)
+	outputDir := filepath.Join(tmpDir, "notes")
+
+	incrementalRun := func(content string) {
+		r := newTestRun(t, tmpDir)
+		r.converter.NoteLinks = internal.NewNoteIndex()
+		if err := r.output.EnableIncremental(false, optionsDigest(r.converter, r.output)); err != nil {
+			t.Fatal(err)
+		}
+		r.run(r.export("export.enex", content))
+	}
+
+	notePath := filepath.Join(outputDir, "Source.md")
+	incrementalRun(linkedFile)
+	if err := os.WriteFile(notePath, []byte("local edits"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	incrementalRun(linkedFile)
+	if b, _ := os.ReadFile(notePath); string(b) != "local edits" {
+		t.Errorf("Unchanged note was overwritten with: %s", b)
+	}
+
+	// The linked note is renamed, so the link in the unchanged note has to follow it
+	incrementalRun(strings.Replace(linkedFile, "<title>Target</title>", "<title>Renamed</title>", 1))
+	if b, _ := os.ReadFile(notePath); string(b) == "local edits" {
+		t.Errorf("Note linking to a changed note was not converted again")
+	}
+}
+
+func Test_manifest_identity(t *testing.T) {
+	note := &enex.Note{Title: "Note", Created: "20200102T030405Z"}
+	m := newManifest()
+	if a, b := m.identity("a.enex", note), m.identity(filepath.FromSlash("dir/b.enex"), note); a == b {
+		t.Errorf("identity() = %s for notes from different exports", a)
+	}
+	if a, b := newManifest().identity("a.enex", note), newManifest().identity(filepath.FromSlash("other/a.enex"), note); a != b {
+		t.Errorf("identity() = %s, %s for the same export in another directory", a, b)
+	}
+}
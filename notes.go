@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/wormi4ok/evernote2md/encoding/markdown"
 	"github.com/wormi4ok/evernote2md/file"
+	"github.com/wormi4ok/evernote2md/internal"
 )
 
 // noteFilesDir saves markdown notes in a directory on the filesystem
@@ -16,11 +20,33 @@ type noteFilesDir struct {
 	path string
 
 	// flags modifying the logic for saving notes
-	flagFolders    bool
-	flagTimestamps bool
+	flagFolders     bool
+	flagTimestamps  bool
+	flagNotebooks   bool
+	flagIncremental bool
+	flagPrune       bool
+	flagLogseq      bool
+
+	// incomplete is set when some notes of the export were not seen in the run
+	incomplete bool
+
+	// Notebook names for export files, by default the file name is used
+	notebooks map[string]string
+	// A directory of the current notebook relative to the output directory
+	notebook string
 
 	// A map to keep track of what notes are already created
 	names map[string]int
+
+	// A list of notes saved in the directory
+	manifest *manifest
+
+	// A directory for attachments shared by all notes and a set of files already stored there
+	attachments string
+	stored      map[string]bool
+
+	// A dry run records the plan instead of writing files
+	plan *conversionPlan
 }
 
 func newNoteFilesDir(output string, folders, timestamps bool) *noteFilesDir {
@@ -29,19 +55,103 @@ func newNoteFilesDir(output string, folders, timestamps bool) *noteFilesDir {
 		flagFolders:    folders,
 		flagTimestamps: timestamps,
 		names:          map[string]int{},
+		manifest:       newManifest(),
 	}
 }
 
 // SaveNote along with media resources
 func (d *noteFilesDir) SaveNote(title string, md *markdown.Note) error {
-	path := d.path
+	return d.save(d.notePath(title), md)
+}
+
+// EnableNotebooks puts notes from every export file in a separate directory named after the notebook.
+// Names in the mapping may contain slashes to nest notebooks in stacks.
+func (d *noteFilesDir) EnableNotebooks(mapping map[string]string) {
+	d.flagNotebooks = true
+	d.notebooks = mapping
+}
+
+// EnableIncremental skips notes that didn't change since the previous run with the same options
+// and removes notes missing in the current run if prune is set
+func (d *noteFilesDir) EnableIncremental(prune bool, options string) error {
+	m, err := readManifest(d.path, options)
+	if err != nil {
+		return err
+	}
+	d.manifest = m
+	d.flagIncremental = true
+	d.flagPrune = prune
+
+	return nil
+}
+
+// EnableAttachmentsDir stores attachments of all notes in one directory relative to the output directory.
+// Attachments are content-addressed, so an attachment shared by many notes is saved only once.
+func (d *noteFilesDir) EnableAttachmentsDir(dir string) {
+	d.attachments = filepath.Clean(dir)
+	d.stored = map[string]bool{}
+}
+
+// EnableDryRun records what would be written to the plan, leaving the output directory untouched
+func (d *noteFilesDir) EnableDryRun(plan *conversionPlan) {
+	d.plan = plan
+}
+
+// EnableLogseq lays out notes as a Logseq graph: daily notes named after a date go to journals,
+// other notes go to pages and attachments are stored in the shared assets directory
+func (d *noteFilesDir) EnableLogseq() {
+	d.flagLogseq = true
+	d.notebook = internal.LogseqPagesDir
+	d.EnableAttachmentsDir(internal.LogseqAssetsDir)
+}
+
+// OpenNotebook switches to the notebook directory for the notes from a given export file
+func (d *noteFilesDir) OpenNotebook(exportFile string) {
+	if !d.flagNotebooks {
+		return
+	}
+
+	var dirs []string
+	for _, name := range strings.Split(notebookName(exportFile, d.notebooks), "/") {
+		if name = file.BaseName(name); name != "" {
+			dirs = append(dirs, name)
+		}
+	}
+	d.notebook = filepath.Join(dirs...)
+}
+
+// notePath reserves a unique path for a new note relative to the output directory
+func (d *noteFilesDir) notePath(title string) string {
+	if d.flagLogseq {
+		if date, ok := internal.JournalDate(title); ok {
+			// Only one journal page per day, other notes for the day become pages
+			journal := filepath.Join(internal.LogseqJournalsDir, internal.LogseqJournalName(date))
+			if _, exist := d.names[journal]; !exist {
+				d.names[journal] = 1
+				return journal
+			}
+		}
+	}
+	name, renamed := d.uniqueName(title)
+	notePath := filepath.Join(d.notebook, name+".md")
 	if d.flagFolders {
-		path = filepath.Join(d.path, d.uniqueName(title))
-		title = "README.md"
-	} else {
-		title = d.uniqueName(title) + ".md"
+		notePath = filepath.Join(d.notebook, name, "README.md")
+	}
+	if renamed && d.plan != nil {
+		d.plan.collision(notePath)
+	}
+
+	return notePath
+}
+
+func (d *noteFilesDir) save(notePath string, md *markdown.Note) error {
+	if d.plan != nil {
+		return nil
 	}
 
+	path := filepath.Join(d.path, filepath.Dir(notePath))
+	title := filepath.Base(notePath)
+
 	log.Printf("[DEBUG] Saving file %s/%s", path, title)
 	if err := file.Save(path, title, bytes.NewReader(md.Content)); err != nil {
 		return fmt.Errorf("save file %s: %w", path+"/"+title, err)
@@ -51,13 +161,17 @@ func (d *noteFilesDir) SaveNote(title string, md *markdown.Note) error {
 		if err := file.ChangeFileTimes(path, title, md.CTime, md.MTime); err != nil {
 			// Continue processing on error
 			log.Printf("[WARN] Error updating file times for a file: %s", title)
+			md.Warn("failed to update file times: %s", err)
 		}
 	}
 
 	for _, res := range md.Media {
-		mediaPath := filepath.Join(path, string(res.Type))
+		mediaPath := filepath.Join(d.path, d.mediaDir(notePath, res))
+		if d.attachments != "" && d.isStored(filepath.Join(mediaPath, res.Name)) {
+			continue
+		}
 		log.Printf("[DEBUG] Saving attachment %s", filepath.Join(mediaPath, res.Name))
-		if err := file.Save(mediaPath, res.Name, bytes.NewReader(res.Content)); err != nil {
+		if err := saveResource(mediaPath, res); err != nil {
 			return fmt.Errorf("save resource %s: %w", filepath.Join(mediaPath, res.Name), err)
 		}
 	}
@@ -65,21 +179,179 @@ func (d *noteFilesDir) SaveNote(title string, md *markdown.Note) error {
 	return nil
 }
 
+// saveResource copies the content of the resource to a file
+func saveResource(dir string, res markdown.Resource) error {
+	content, err := res.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = content.Close() }()
+
+	return file.Save(dir, res.Name, content)
+}
+
+// unchanged reports whether the note can be skipped, because it is the same as in the previous run
+func (d *noteFilesDir) unchanged(e manifestEntry) bool {
+	return d.flagIncremental && d.manifest.unchanged(d.path, e)
+}
+
+// record a saved note in the manifest
+func (d *noteFilesDir) record(e manifestEntry, md *markdown.Note) {
+	e.Media = d.mediaPaths(e.Path, md)
+	d.manifest.add(e)
+}
+
+// mediaPaths of the note attachments relative to the output directory
+func (d *noteFilesDir) mediaPaths(notePath string, md *markdown.Note) []string {
+	var paths []string
+	for _, res := range md.Media {
+		paths = append(paths, filepath.ToSlash(filepath.Join(d.mediaDir(notePath, res), res.Name)))
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// markIncomplete keeps notes missing in the current run, because the run didn't see the whole export
+func (d *noteFilesDir) markIncomplete() {
+	d.incomplete = true
+}
+
+// Close reports notes missing in the current run, removes them if pruning is enabled
+// and saves the manifest for the next run, only in incremental mode
+func (d *noteFilesDir) Close() error {
+	if !d.flagIncremental {
+		return nil
+	}
+
+	// Attachments with the same name may be shared by notes in the same folder
+	inUse := map[string]bool{}
+	for _, e := range d.manifest.Notes {
+		for _, m := range e.Media {
+			inUse[m] = true
+		}
+	}
+	removed := d.manifest.removed()
+	if d.incomplete {
+		// Notes from the part of the export the run didn't see are not missing
+		removed = nil
+	}
+	for _, e := range removed {
+		if d.plan != nil && d.flagPrune {
+			d.plan.remove(e.Path)
+			continue
+		}
+		if !d.flagPrune {
+			log.Printf("[WARN] Note is missing in the export: %s", e.Path)
+			continue
+		}
+		log.Printf("[DEBUG] Removing note %s", e.Path)
+		for _, p := range append(e.Media, e.Path) {
+			if inUse[p] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(d.path, filepath.FromSlash(p))); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove note %s: %w", e.Path, err)
+			}
+		}
+		if d.flagFolders {
+			// Remove the note folder unless there are other files
+			_ = os.Remove(filepath.Join(d.path, filepath.FromSlash(path.Dir(e.Path))))
+		}
+	}
+	d.manifest.keepPrevious(d.flagPrune && !d.incomplete)
+	if d.plan != nil {
+		return nil
+	}
+
+	return d.manifest.save(d.path)
+}
+
+// mediaDir is a directory for note attachments relative to the output directory
+func (d *noteFilesDir) mediaDir(notePath string, res markdown.Resource) string {
+	if d.attachments != "" {
+		return d.attachments
+	}
+
+	return filepath.Join(filepath.Dir(filepath.FromSlash(notePath)), string(res.Type))
+}
+
+// isStored checks if a shared attachment was saved by a previous note or a previous run
+func (d *noteFilesDir) isStored(path string) bool {
+	if d.stored[path] {
+		return true
+	}
+	d.stored[path] = true
+	_, err := os.Stat(path)
+
+	return err == nil
+}
+
+// planner returns a copy of the directory to predict note paths
+// without affecting the names reserved in the original one
+func (d *noteFilesDir) planner() *noteFilesDir {
+	p := *d
+	p.names = map[string]int{}
+	p.plan = nil
+
+	return &p
+}
+
 func (d *noteFilesDir) Path() string {
 	return d.path
 }
 
-// uniqueName returns a unique note name
-func (d *noteFilesDir) uniqueName(title string) string {
+// uniqueName returns a note name unique within the current notebook
+// and whether it was renamed because the name is taken
+func (d *noteFilesDir) uniqueName(title string) (string, bool) {
 	name := file.BaseName(title)
-	index := strings.ToLower(name)
+	index := strings.ToLower(filepath.Join(d.notebook, name))
 
 	if k, exist := d.names[index]; exist {
 		d.names[index] = k + 1
-		name = fmt.Sprintf("%s-%d", name, k)
-	} else {
-		d.names[index] = 1
+		return fmt.Sprintf("%s-%d", name, k), true
+	}
+	d.names[index] = 1
+
+	return name, false
+}
+
+// notebookName for an export file taken from the mapping or the file name itself
+func notebookName(exportFile string, mapping map[string]string) string {
+	base := filepath.Base(exportFile)
+	ext := filepath.Ext(base)
+	for _, key := range []string{base, strings.TrimSuffix(base, ext)} {
+		if name, ok := mapping[key]; ok {
+			return name
+		}
+	}
+
+	return strings.TrimSuffix(base, ext)
+}
+
+// readNotebookMapping parses a file with lines in the format:
+//
+//	<export file name> = <stack>/<notebook>
+//
+// Empty lines and lines starting with # are ignored
+func readNotebookMapping(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read notebook mapping: %w", err)
+	}
+
+	mapping := map[string]string{}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("read notebook mapping: line %d: missing '='", i+1)
+		}
+		mapping[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), "/")
 	}
 
-	return name
+	return mapping, nil
 }
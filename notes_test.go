@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -86,14 +88,96 @@ func TestNoteFilesDir_UniqueNames_CaseInsensitive(t *testing.T) {
 	shouldExist(t, tmpDir, "/test_note-1.md")
 }
 
+// Test that notes with identical names in different notebooks are saved in separate folders
+func TestNoteFilesDir_Notebooks(t *testing.T) {
+	tmpDir := t.TempDir()
+	d := newNoteFilesDir(tmpDir, false, false)
+	d.EnableNotebooks(map[string]string{"Recipes.enex": "Home/Recipes"})
+
+	md := fakeNote(time.Now())
+	for _, exportFile := range []string{"exports/Work.enex", "exports/Recipes.enex", "exports/Recipes.enex"} {
+		d.OpenNotebook(exportFile)
+		if err := d.SaveNote("test_note", md); err != nil {
+			t.Errorf("SaveNote returned error: %s", err.Error())
+		}
+	}
+
+	shouldExist(t, tmpDir, "/Work/test_note.md")
+	shouldExist(t, tmpDir, "/Work/image/test.jpg")
+	shouldExist(t, tmpDir, "/Home/Recipes/test_note.md")
+	shouldExist(t, tmpDir, "/Home/Recipes/test_note-1.md")
+	shouldExist(t, tmpDir, "/Home/Recipes/image/test.jpg")
+}
+
+// Test that attachments in the shared directory are saved once
+func TestNoteFilesDir_AttachmentsDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	d := newNoteFilesDir(tmpDir, true, false)
+	d.EnableAttachmentsDir("attachments")
+
+	md := fakeNote(time.Now())
+	for range 2 {
+		if err := d.SaveNote("test_note", md); err != nil {
+			t.Errorf("SaveNote returned error: %s", err.Error())
+		}
+	}
+
+	shouldExist(t, tmpDir, "/attachments/test.jpg")
+	if _, err := os.Stat(filepath.Join(tmpDir, "test_note", "image")); !os.IsNotExist(err) {
+		t.Errorf("Attachments should not be saved next to the note")
+	}
+	if got := len(d.stored); got != 1 {
+		t.Errorf("Attachment was saved %d times, want once", got)
+	}
+}
+
+// Test that daily notes go to journals and only one note per day is a journal page
+func TestNoteFilesDir_Logseq(t *testing.T) {
+	tmpDir := t.TempDir()
+	d := newNoteFilesDir(tmpDir, false, false)
+	d.EnableLogseq()
+
+	md := fakeNote(time.Now())
+	for _, title := range []string{"Meeting notes", "2021-03-04", "March 4, 2021"} {
+		if err := d.SaveNote(title, md); err != nil {
+			t.Errorf("SaveNote returned error: %s", err.Error())
+		}
+	}
+
+	shouldExist(t, tmpDir, "/pages/Meeting_notes.md")
+	shouldExist(t, tmpDir, "/journals/2021_03_04.md")
+	shouldExist(t, tmpDir, "/pages/March_4,_2021.md")
+	shouldExist(t, tmpDir, "/assets/test.jpg")
+}
+
+func Test_readNotebookMapping(t *testing.T) {
+	mappingFile := filepath.Join(t.TempDir(), "notebooks.txt")
+	content := "# Stacks\n\nRecipes.enex = Home/Recipes/\nWork=Job\n"
+	if err := os.WriteFile(mappingFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readNotebookMapping(mappingFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Recipes.enex": "Home/Recipes", "Work": "Job"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readNotebookMapping() = %v, want %v", got, want)
+	}
+}
+
 func fakeNote(wantDate time.Time) *markdown.Note {
 	return &markdown.Note{
 		Content: []byte(`12345`),
 		Media: map[string]markdown.Resource{
 			"123": {
-				Name:    "test.jpg",
-				Type:    "image",
-				Content: []byte(`fakeContent`),
+				Name: "test.jpg",
+				Type: "image",
+				Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader(`fakeContent`)), nil
+				},
+				Size: int64(len(`fakeContent`)),
 			},
 		},
 		CTime: wantDate,
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/encoding/markdown"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+// noteJob is a note passing through the conversion pipeline:
+// decoder -> converters -> writer
+type noteJob struct {
+	// seq is the position of the note in the input
+	seq  int
+	file string
+	note enex.Note
+
+	// path reserved for the note relative to the output directory
+	path  string
+	entry manifestEntry
+	// unchanged notes skip conversion
+	unchanged bool
+	// excluded notes don't match the filters and are neither converted nor saved
+	excluded bool
+	// broken is set instead of a note when the input file can't be read or decoded
+	broken bool
+	// unreadable is set for broken jobs of files which can't be opened at all
+	unreadable bool
+
+	md  *markdown.Note
+	err error
+	// time spent converting the note
+	duration time.Duration
+}
+
+// decodeNotes reads notes from the input files in order and reserves output paths,
+// so that note names are assigned in the same order regardless of the number of workers.
+// Every job takes a slot, which is released by the writer, to limit the notes kept in memory.
+// Notes not matching the filter don't reserve a path, but pass through to keep their manifest entries.
+// Input files that can't be read or decoded pass through as broken jobs.
+// Decoding stops early when the stop channel is closed.
+func decodeNotes(files []string, output *noteFilesDir, filter *noteFilter, index *internal.NoteIndex, jobs chan<- *noteJob, slots chan<- struct{}, stop <-chan struct{}) {
+	defer close(jobs)
+
+	seq := 0
+	send := func(j *noteJob) bool {
+		j.seq = seq
+		slots <- struct{}{}
+		select {
+		case jobs <- j:
+			seq++
+			return true
+		case <-stop:
+			_ = j.note.Close()
+			return false
+		}
+	}
+
+	for _, file := range files {
+		if !decodeFile(file, output, filter, index, send) {
+			return
+		}
+	}
+}
+
+// decodeFile sends jobs for all notes of the export file, it returns false if decoding was stopped
+func decodeFile(file string, output *noteFilesDir, filter *noteFilter, index *internal.NoteIndex, send func(j *noteJob) bool) bool {
+	fd, err := os.Open(file)
+	if err != nil {
+		return send(&noteJob{file: file, broken: true, unreadable: true, err: err})
+	}
+	defer func() { _ = fd.Close() }()
+
+	log.Printf("[DEBUG] Decoding file: %s", file)
+	output.OpenNotebook(file)
+	d, err := enex.NewStreamDecoder(fd)
+	if err != nil {
+		return send(&noteJob{file: file, broken: true, err: fmt.Errorf("decode file: %w", err)})
+	}
+
+	for {
+		j := &noteJob{file: file}
+		if err := d.Next(&j.note); err != nil {
+			_ = j.note.Close()
+			if err != io.EOF {
+				return send(&noteJob{file: file, broken: true, err: fmt.Errorf("decode the next note: %w", err)})
+			}
+			return true
+		}
+		j.note.Notebook = notebookName(file, output.notebooks)
+		if filter.match(file, &j.note) {
+			// Reserve the path first to keep note names consistent with the index
+			j.path = output.notePath(j.note.Title)
+			var targets []string
+			if index != nil {
+				// Notes are converted again when the notes they link to move
+				targets = index.Targets(&j.note)
+			}
+			j.entry = output.manifest.entry(file, &j.note, j.path, targets)
+			j.unchanged = output.unchanged(j.entry)
+		} else {
+			j.entry = manifestEntry{ID: output.manifest.identity(file, &j.note)}
+			j.excluded = true
+		}
+
+		if !send(j) {
+			return false
+		}
+	}
+}
+
+// convertNotes starts a number of workers converting notes concurrently
+// The output channel is closed when all the jobs are processed
+func convertNotes(c *internal.Converter, workers int, jobs <-chan *noteJob) <-chan *noteJob {
+	converted := make(chan *noteJob)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for j := range jobs {
+				if !j.unchanged && !j.excluded && !j.broken {
+					start := time.Now()
+					j.md, j.err = c.ConvertTo(&j.note, filepath.ToSlash(j.path))
+					j.duration = time.Since(start)
+				}
+				converted <- j
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(converted)
+	}()
+
+	return converted
+}
+
+// inOrder calls fn for every converted note in the order of the input files
+// and releases the slot taken by the decoder
+func inOrder(converted <-chan *noteJob, slots <-chan struct{}, fn func(j *noteJob)) {
+	pending := map[int]*noteJob{}
+	next := 0
+	for j := range converted {
+		pending[j.seq] = j
+		for j, ok := pending[next]; ok; j, ok = pending[next] {
+			delete(pending, next)
+			fn(j)
+			<-slots
+			next++
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that parallel conversion assigns note names in the order of the input
+func Test_run_parallel(t *testing.T) {
+	tmpDir := tDir(t)
+	r := newTestRun(t, tmpDir)
+	r.output = newNoteFilesDir(tmpDir, false, false)
+	r.opts.jobs = 8
+
+	var export strings.Builder
+	export.WriteString(`<?xml version="1.0" encoding="UTF-8"?><en-export>`)
+	for i := range 50 {
+		_, _ = fmt.Fprintf(&export, `<note><title>Same</title><content><![CDATA[<en-note><div>note %d</div></en-note>]]></content></note>`, i)
+	}
+	export.WriteString(`</en-export>`)
+	r.run(r.export("export.enex", export.String()))
+
+	for i := range 50 {
+		name := "Same.md"
+		if i > 0 {
+			name = fmt.Sprintf("Same-%d.md", i)
+		}
+		b, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("note %d\n", i); !strings.HasSuffix(string(b), want) {
+			t.Errorf("%s = %q, want to end with %q", name, b, want)
+		}
+	}
+}
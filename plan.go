@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Statuses of notes in the conversion plan
+const (
+	planConvert   = "convert"
+	planUnchanged = "unchanged"
+	planSkipped   = "skipped"
+	planFailed    = "failed"
+)
+
+type (
+	// conversionPlan describes what a run would do without writing anything to the output directory
+	conversionPlan struct {
+		Notes []plannedNote `json:"notes"`
+		// notes from the previous run that would be pruned
+		Removed []string `json:"removed,omitempty"`
+
+		// a JSON file to save the plan to, or - for stdout, the plan is printed as text if empty
+		file string
+
+		mu sync.Mutex
+		// paths with a suffix added because of a name collision, collected while reserving paths
+		collisions map[string]bool
+	}
+
+	plannedNote struct {
+		Title  string `json:"title"`
+		Source string `json:"source"`
+		Status string `json:"status"`
+		// path relative to the output directory
+		Path        string   `json:"path,omitempty"`
+		Attachments []string `json:"attachments,omitempty"`
+		// Collision is set when the path has a -N suffix, because the name is taken by another note
+		Collision bool   `json:"collision,omitempty"`
+		Error     string `json:"error,omitempty"`
+	}
+)
+
+func newConversionPlan(file string) *conversionPlan {
+	return &conversionPlan{Notes: []plannedNote{}, file: file, collisions: map[string]bool{}}
+}
+
+// collision records a path renamed to avoid a name collision
+func (p *conversionPlan) collision(notePath string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.collisions[filepath.ToSlash(notePath)] = true
+}
+
+// add a note passed through the pipeline with paths of its attachments
+func (p *conversionPlan) add(j *noteJob, attachments []string) {
+	n := plannedNote{
+		Title:       j.note.Title,
+		Source:      filepath.Base(j.file),
+		Status:      planConvert,
+		Path:        filepath.ToSlash(j.path),
+		Attachments: attachments,
+	}
+	switch {
+	case j.excluded:
+		n.Status = planSkipped
+	case j.unchanged:
+		n.Status = planUnchanged
+	case j.err != nil:
+		n.Status, n.Error, n.Attachments = planFailed, j.err.Error(), nil
+	}
+	p.Notes = append(p.Notes, n)
+}
+
+// remove records a note that would be pruned
+func (p *conversionPlan) remove(notePath string) {
+	p.Removed = append(p.Removed, filepath.ToSlash(notePath))
+}
+
+// count notes with a given status
+func (p *conversionPlan) count(status string) int {
+	cnt := 0
+	for _, n := range p.Notes {
+		if n.Status == status {
+			cnt++
+		}
+	}
+
+	return cnt
+}
+
+// report prints the plan as text or saves it as JSON
+func (p *conversionPlan) report() error {
+	p.mu.Lock()
+	for i := range p.Notes {
+		p.Notes[i].Collision = p.collisions[p.Notes[i].Path]
+	}
+	p.mu.Unlock()
+	sort.Strings(p.Removed)
+
+	switch p.file {
+	case "":
+		return p.writeText(os.Stdout)
+	case "-":
+		return p.writeJSON(os.Stdout)
+	}
+
+	f, err := os.Create(p.file)
+	if err != nil {
+		return fmt.Errorf("save plan: %w", err)
+	}
+	if err := p.writeJSON(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("save plan: %w", err)
+	}
+
+	return f.Close()
+}
+
+func (p *conversionPlan) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(p)
+}
+
+func (p *conversionPlan) writeText(w io.Writer) error {
+	var b strings.Builder
+	for _, n := range p.Notes {
+		switch n.Status {
+		case planFailed:
+			if n.Title == "" {
+				fmt.Fprintf(&b, "! %s would fail: %s\n", n.Source, n.Error)
+				continue
+			}
+			fmt.Fprintf(&b, "! %q from %s would fail: %s\n", n.Title, n.Source, n.Error)
+			continue
+		case planSkipped:
+			fmt.Fprintf(&b, "- %q from %s is skipped by the filters\n", n.Title, n.Source)
+			continue
+		case planUnchanged:
+			fmt.Fprintf(&b, "= %s is unchanged\n", n.Path)
+			continue
+		}
+		fmt.Fprintf(&b, "+ %s <- %q from %s", n.Path, n.Title, n.Source)
+		if n.Collision {
+			b.WriteString(", renamed after a name collision")
+		}
+		b.WriteString("\n")
+		for _, a := range n.Attachments {
+			fmt.Fprintf(&b, "    %s\n", a)
+		}
+	}
+	for _, r := range p.Removed {
+		fmt.Fprintf(&b, "x %s would be removed\n", r)
+	}
+	fmt.Fprintf(&b, "Dry run: %d notes to convert, %d unchanged, %d skipped, %d failing, %d to remove\n",
+		p.count(planConvert), p.count(planUnchanged), p.count(planSkipped), p.count(planFailed), len(p.Removed))
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+const planFile = `<?xml version="1.0" encoding="UTF-8"?>
+<en-export>
+<note><title>Shopping</title><content><![CDATA[<en-note><en-media type="text/plain" hash="eb5c1399a871211c7e7ed732d15e3a8b"/></en-note>]]></content>
+<resource><data encoding="base64">c21hbGw=</data><mime>text/plain</mime>
+<resource-attributes><source-url>en-cache://res/eb5c1399a871211c7e7ed732d15e3a8b</source-url><file-name>list.txt</file-name></resource-attributes></resource></note>
+<note><title>Shopping</title><content><![CDATA[<en-note><div>again</div></en-note>]]></content></note>
+<note><title>Broken</title><content><![CDATA[<en-note><en-media type="text/plain" hash="00000000000000000000000000000000"/></en-note>]]></content>
+<resource><data encoding="base64">c21hbGw=</data><mime>text/plain</mime>
+<resource-attributes><source-url>en-cache://res/00000000000000000000000000000000</source-url></resource-attributes></resource></note>
+</en-export>
+`
+
+func Test_run_dryRun(t *testing.T) {
+	r := newTestRun(t, tDir(t))
+	planPath := filepath.Join(r.dir, "plan.json")
+	r.output.EnableDryRun(newConversionPlan(planPath))
+	r.converter.ResourceCheck = internal.NewResourceCheck()
+	r.converter.StrictResources = true
+	r.run(r.export("export.enex", planFile))
+
+	if _, err := os.Stat(r.output.Path()); !os.IsNotExist(err) {
+		t.Errorf("Output directory was created in a dry run: %v", err)
+	}
+
+	b, err := os.ReadFile(planPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got conversionPlan
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Notes) == 3 && got.Notes[2].Error != "" {
+		got.Notes[2].Error = "error"
+	}
+	want := []plannedNote{
+		{Title: "Shopping", Source: "export.enex", Status: planConvert, Path: "Shopping.md", Attachments: []string{"file/list.txt"}},
+		{Title: "Shopping", Source: "export.enex", Status: planConvert, Path: "Shopping-1.md", Collision: true},
+		{Title: "Broken", Source: "export.enex", Status: planFailed, Path: "Broken.md", Error: "error"},
+	}
+	if !reflect.DeepEqual(got.Notes, want) {
+		t.Errorf("plan notes\n got  %+v\n want %+v", got.Notes, want)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+)
+
+// reminderReport lists notes with Evernote reminders in a CSV file,
+// because markdown files can't keep reminder schedules
+type reminderReport struct {
+	path string
+	rows [][]string
+}
+
+var reminderColumns = []string{"title", "path", "notebook", "status", "reminder_time", "reminder_done_time", "reminder_order"}
+
+func newReminderReport(path string) *reminderReport {
+	return &reminderReport{path: path}
+}
+
+// add the note to the report if it has a reminder
+func (r *reminderReport) add(note *enex.Note, notePath string) {
+	a := note.Attributes
+	if !a.HasReminder() {
+		return
+	}
+
+	status := "pinned"
+	switch {
+	case a.ReminderDoneTime != "":
+		status = "done"
+	case a.ReminderTime != "":
+		status = "scheduled"
+	}
+	r.rows = append(r.rows, []string{
+		note.Title,
+		filepath.ToSlash(notePath),
+		note.Notebook,
+		status,
+		reminderDate(a.ReminderTime),
+		reminderDate(a.ReminderDoneTime),
+		a.ReminderOrder,
+	})
+}
+
+// save the report, it is created even if there are no reminders
+func (r *reminderReport) save() error {
+	f, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("save reminders: %w", err)
+	}
+
+	w := csv.NewWriter(f)
+	_ = w.Write(reminderColumns)
+	_ = w.WriteAll(r.rows)
+	if err := w.Error(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("save reminders: %w", err)
+	}
+
+	return f.Close()
+}
+
+// reminderDate in RFC 3339 format, which spreadsheets and calendars understand
+func reminderDate(date string) string {
+	t, err := enex.ParseDate(date)
+	if err != nil {
+		return date
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const reminderFile = `<?xml version="1.0" encoding="UTF-8"?>
+<en-export>
+<note><title>Call the bank</title><content><![CDATA[<en-note><div>About the card</div></en-note>]]></content>
+<note-attributes><reminder-order>1606813200000</reminder-order><reminder-time>20201205T080000Z</reminder-time><reminder-done-time>20201205T093000Z</reminder-done-time></note-attributes></note>
+<note><title>Not a reminder</title><content><![CDATA[<en-note><div>Text</div></en-note>]]></content></note>
+<note><title>Pinned</title><content><![CDATA[<en-note><div>Text</div></en-note>]]></content>
+<note-attributes><reminder-order>1606813200001</reminder-order></note-attributes></note>
+</en-export>
+`
+
+func Test_run_reminders(t *testing.T) {
+	r := newTestRun(t, tDir(t))
+	reportFile := filepath.Join(r.dir, "reminders.csv")
+	r.opts = runOptions{jobs: 1, reminders: newReminderReport(reportFile)}
+	r.run(r.export("Tasks.enex", reminderFile))
+
+	f, err := os.Open(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		reminderColumns,
+		{"Call the bank", "Call_the_bank.md", "Tasks", "done", "2020-12-05T08:00:00Z", "2020-12-05T09:30:00Z", "1606813200000"},
+		{"Pinned", "Pinned.md", "Tasks", "pinned", "", "", "1606813200001"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reminders report = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses of notes in the conversion report
+const (
+	reportConverted = "converted"
+	reportUnchanged = "unchanged"
+	reportSkipped   = "skipped"
+	reportFailed    = "failed"
+)
+
+type (
+	// conversionReport describes the result of a run for every note,
+	// it is saved as CSV if the file name ends with .csv and as JSON otherwise
+	conversionReport struct {
+		Started    time.Time      `json:"started"`
+		DurationMS int64          `json:"duration_ms"`
+		Totals     reportTotals   `json:"totals"`
+		Notes      []reportedNote `json:"notes"`
+
+		path string
+		// relativeTo is the working directory when the input was given as a relative path
+		relativeTo string
+	}
+
+	reportTotals struct {
+		Notes     int `json:"notes"`
+		Converted int `json:"converted"`
+		Unchanged int `json:"unchanged"`
+		Skipped   int `json:"skipped"`
+		Failed    int `json:"failed"`
+		Warnings  int `json:"warnings"`
+		// input files that couldn't be read or decoded
+		FailedFiles int `json:"failed_files"`
+	}
+
+	reportedNote struct {
+		Title  string `json:"title"`
+		Source string `json:"source"`
+		Status string `json:"status"`
+		// path relative to the output directory
+		Path     string   `json:"path,omitempty"`
+		Error    string   `json:"error,omitempty"`
+		Warnings []string `json:"warnings,omitempty"`
+		// DurationMS is the time spent converting the note
+		DurationMS int64 `json:"duration_ms"`
+	}
+)
+
+var reportColumns = []string{"title", "source", "status", "path", "error", "warnings", "duration_ms"}
+
+func newConversionReport(path string) *conversionReport {
+	return &conversionReport{Started: time.Now(), Notes: []reportedNote{}, path: path}
+}
+
+// source is the path of the export file in the same form as the input on the command line
+func (r *conversionReport) source(file string) string {
+	if r.relativeTo != "" {
+		if rel, err := filepath.Rel(r.relativeTo, file); err == nil {
+			return rel
+		}
+	}
+
+	return file
+}
+
+// add a note passed through the pipeline, the writer sets the error if saving failed
+func (r *conversionReport) add(j *noteJob) {
+	n := reportedNote{
+		Title:      j.note.Title,
+		Source:     r.source(j.file),
+		Status:     reportConverted,
+		Path:       filepath.ToSlash(j.path),
+		DurationMS: j.duration.Milliseconds(),
+	}
+	switch {
+	case j.broken:
+		// The title is empty, the error describes the input file
+		n.Status, n.Error = reportFailed, j.err.Error()
+		r.Totals.FailedFiles++
+		r.Notes = append(r.Notes, n)
+		return
+	case j.excluded:
+		n.Status = reportSkipped
+		r.Totals.Skipped++
+	case j.unchanged:
+		n.Status = reportUnchanged
+		r.Totals.Unchanged++
+	case j.err != nil:
+		n.Status, n.Error = reportFailed, j.err.Error()
+		r.Totals.Failed++
+	default:
+		r.Totals.Converted++
+	}
+	if j.md != nil {
+		n.Warnings = j.md.Warnings
+		r.Totals.Warnings += len(n.Warnings)
+	}
+	r.Totals.Notes++
+	r.Notes = append(r.Notes, n)
+}
+
+// save the report at the end of the run
+func (r *conversionReport) save() error {
+	r.DurationMS = time.Since(r.Started).Milliseconds()
+
+	f, err := os.Create(r.path)
+	if err != nil {
+		return fmt.Errorf("save report: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(r.path), ".csv") {
+		err = r.writeCSV(f)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	}
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("save report: %w", err)
+	}
+
+	return f.Close()
+}
+
+func (r *conversionReport) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(reportColumns)
+	for _, n := range r.Notes {
+		_ = cw.Write([]string{
+			n.Title,
+			n.Source,
+			n.Status,
+			n.Path,
+			n.Error,
+			strings.Join(n.Warnings, "; "),
+			strconv.FormatInt(n.DurationMS, 10),
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+const reportFile = `<?xml version="1.0" encoding="UTF-8"?>
+<en-export>
+<note><title>Good</title><content><![CDATA[<en-note><div>text</div></en-note>]]></content></note>
+<note><title>Missing</title><content><![CDATA[<en-note><en-media type="image/png" hash="0cc175b9c0f1b6a831c399e269772661"/></en-note>]]></content></note>
+<note><title>Broken</title><content><![CDATA[<en-note><en-media type="text/plain" hash="00000000000000000000000000000000"/></en-note>]]></content>
+<resource><data encoding="base64">c21hbGw=</data><mime>text/plain</mime>
+<resource-attributes><source-url>en-cache://res/00000000000000000000000000000000</source-url></resource-attributes></resource></note>
+</en-export>
+`
+
+func runReport(t *testing.T, name string) string {
+	t.Helper()
+	r := newTestRun(t, tDir(t))
+	r.converter.ResourceCheck = internal.NewResourceCheck()
+	r.converter.StrictResources = true
+	reportPath := filepath.Join(r.dir, name)
+	r.opts.report = newConversionReport(reportPath)
+	r.run(r.export("export.enex", reportFile))
+
+	return reportPath
+}
+
+func Test_run_report(t *testing.T) {
+	reportPath := runReport(t, "report.json")
+	b, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got conversionReport
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (reportTotals{Notes: 3, Converted: 2, Failed: 1, Warnings: 1}); got.Totals != want {
+		t.Errorf("report totals = %+v, want %+v", got.Totals, want)
+	}
+	if len(got.Notes) != 3 {
+		t.Fatalf("report notes = %d, want 3", len(got.Notes))
+	}
+	source := filepath.Join(filepath.Dir(reportPath), "export.enex")
+	for i, want := range []reportedNote{
+		{Title: "Good", Source: source, Status: reportConverted, Path: "Good.md"},
+		{Title: "Missing", Source: source, Status: reportConverted, Path: "Missing.md",
+			Warnings: []string{"attachment 0cc175b9c0f1b6a831c399e269772661 is missing in the export"}},
+	} {
+		got.Notes[i].DurationMS = 0
+		if !reflect.DeepEqual(got.Notes[i], want) {
+			t.Errorf("report note %d\n got  %+v\n want %+v", i, got.Notes[i], want)
+		}
+	}
+	if n := got.Notes[2]; n.Status != reportFailed || n.Error == "" {
+		t.Errorf("report note Broken = %+v, want it failed with an error", n)
+	}
+}
+
+func Test_run_reportCSV(t *testing.T) {
+	f, err := os.Open(runReport(t, "report.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 4 || !reflect.DeepEqual(rows[0], reportColumns) {
+		t.Fatalf("report rows = %v, want a header and 3 notes", rows)
+	}
+	if got := rows[2][2:4]; !reflect.DeepEqual(got, []string{reportConverted, "Missing.md"}) {
+		t.Errorf("report row = %v", rows[2])
+	}
+	if got := rows[3][2]; got != reportFailed {
+		t.Errorf("report status of Broken = %s, want %s", got, reportFailed)
+	}
+}
+
+func Test_conversionReport_source(t *testing.T) {
+	file := filepath.FromSlash("/home/exports/Notes.enex")
+	if got := newConversionReport("").source(file); got != file {
+		t.Errorf("source() = %s, want %s", got, file)
+	}
+	r := newConversionReport("")
+	r.relativeTo = filepath.FromSlash("/home")
+	if got, want := r.source(file), filepath.FromSlash("exports/Notes.enex"); got != want {
+		t.Errorf("source() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/hako/durafmt"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+// reverseRun converts a directory of markdown notes with their attachments to an export file
+func reverseRun(input, output string, sp *spinner.Spinner, r *internal.Reverse) runStats {
+	files, err := matchMarkdown(input)
+	failUsage(err)
+
+	f, err := os.Create(output)
+	failWhen(err)
+
+	start := time.Now()
+	sp.Start()
+
+	var stats runStats
+	enc := enex.NewStreamEncoder(f, time.Now().UTC().Format(enex.DateFormat))
+	for _, file := range files {
+		note, err := reverseNote(r, file)
+		if progressError(err, file, "Failed to convert note") {
+			stats.failed++
+			continue
+		}
+		failWhen(enc.Encode(note))
+		stats.converted++
+	}
+	failWhen(enc.Close())
+	failWhen(f.Close())
+
+	sp.FinalMSG = fmt.Sprintf("Done!\nConverted %d notes to %s in %s\n", stats.converted, output, durafmt.ParseShort(time.Since(start)))
+	sp.Stop()
+
+	return stats
+}
+
+func reverseNote(r *internal.Reverse, file string) (*enex.Note, error) {
+	log.Printf("[DEBUG] Converting file: %s", file)
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Convert(content, file, info.ModTime())
+}
+
+// matchMarkdown finds markdown files in the directory and its subdirectories
+// skipping hidden ones, like the settings of Obsidian or Logseq
+func matchMarkdown(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".md") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err == nil && files == nil {
+		err = fmt.Errorf("no markdown files found in the path: %s", dir)
+	}
+	sort.Strings(files)
+
+	return files, err
+}
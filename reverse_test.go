@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wormi4ok/evernote2md/encoding/enex"
+	"github.com/wormi4ok/evernote2md/internal"
+)
+
+const reverseFile = `<?xml version="1.0" encoding="UTF-8"?>
+<en-export>
+<note><title>Shopping list</title><content><![CDATA[<en-note><div>Buy <b>milk</b></div><en-media type="text/plain" hash="eb5c1399a871211c7e7ed732d15e3a8b"/></en-note>]]></content>
+<created>20201201T090000Z</created><updated>20201202T090000Z</updated><tag>home</tag><tag>food</tag>
+<note-attributes><author>Jane Doe</author><source-url>https://example.com/list</source-url></note-attributes>
+<resource><data encoding="base64">c21hbGw=</data><mime>text/plain</mime>
+<resource-attributes><source-url>en-cache://res/eb5c1399a871211c7e7ed732d15e3a8b</source-url><file-name>list.txt</file-name></resource-attributes></resource></note>
+</en-export>
+`
+
+func Test_reverseRun(t *testing.T) {
+	tmpDir := tDir(t)
+	r := newTestRun(t, tmpDir)
+	r.converter.EnableFrontMatter = true
+	r.opts.jobs = 1
+	r.run(r.export("Shopping.enex", reverseFile))
+
+	export := filepath.Join(tmpDir, "notes.enex")
+	reverseRun(filepath.Join(tmpDir, "notes"), export, newSpinner(true), internal.NewReverse(""))
+
+	f, err := os.Open(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := enex.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Notes) != 1 {
+		t.Fatalf("Decode() notes = %d, want 1", len(got.Notes))
+	}
+
+	note := got.Notes[0]
+	if note.Title != "Shopping list" || note.Created != "20201201T090000Z" || note.Updated != "20201202T090000Z" {
+		t.Errorf("Decode() note = %s created %s updated %s", note.Title, note.Created, note.Updated)
+	}
+	if !reflect.DeepEqual(note.Tags, []string{"home", "food"}) {
+		t.Errorf("Decode() tags = %v, want [home food]", note.Tags)
+	}
+	if note.Attributes.Author != "Jane Doe" || note.Attributes.SourceUrl != "https://example.com/list" {
+		t.Errorf("Decode() attributes = %+v", note.Attributes)
+	}
+	wantContent := "<p>Buy <strong>milk</strong>\n<en-media type=\"text/plain\" hash=\"eb5c1399a871211c7e7ed732d15e3a8b\"></en-media></p>\n"
+	if string(note.Content) != wantContent {
+		t.Errorf("Decode() content = %q, want %q", note.Content, wantContent)
+	}
+	if len(note.Resources) != 1 || note.Resources[0].Attributes.Filename != "list.txt" || string(note.Resources[0].Data.Content) != "c21hbGw=" {
+		t.Errorf("Decode() resources = %+v", note.Resources)
+	}
+}